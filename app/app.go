@@ -2,17 +2,24 @@ package app
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
+	"net"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/intob/logd/store"
+	"github.com/intob/logd/udp"
 	"golang.org/x/time/rate"
 )
 
 type App struct {
 	logStore                 *store.Store
+	udpSvc                   *udp.UdpSvc
+	writeTokens              []string
+	readTokens               []string
 	rateLimitEvery           time.Duration
 	rateLimitBurst           int
 	laddrPort                string
@@ -24,10 +31,14 @@ type App struct {
 	clientMu                 sync.Mutex
 	clients                  map[string]*client
 	statusJson               []byte
+	ui                       http.Handler
+	tailTokenKey             []byte // signs tail tokens, until restarted
 }
 
 type Cfg struct {
 	LogStore                 *store.Store
+	UdpSvc                   *udp.UdpSvc
+	Secrets                  *udp.Secrets
 	Commit                   []byte
 	LaddrPort                string        `yaml:"laddr_port"`
 	RateLimitEvery           time.Duration `yaml:"rate_limit_every"`
@@ -35,6 +46,8 @@ type Cfg struct {
 	TLSCertFname             string        `yaml:"tls_cert_fname"`
 	TLSKeyFname              string        `yaml:"tls_key_fname"`
 	AccessControlAllowOrigin string        `yaml:"access_control_allow_origin"`
+	WriteTokens              []string      `yaml:"write_tokens"` // bearer tokens for POST /write, besides the write secret
+	ReadTokens               []string      `yaml:"read_tokens"`  // bearer tokens for GET /query, besides the read secret
 }
 
 type client struct {
@@ -45,6 +58,9 @@ type client struct {
 func NewApp(ctx context.Context, cfg *Cfg) *App {
 	app := &App{
 		logStore:                 cfg.LogStore,
+		udpSvc:                   cfg.UdpSvc,
+		writeTokens:              cfg.WriteTokens,
+		readTokens:               cfg.ReadTokens,
 		rateLimitEvery:           cfg.RateLimitEvery,
 		rateLimitBurst:           cfg.RateLimitBurst,
 		laddrPort:                cfg.LaddrPort,
@@ -54,6 +70,16 @@ func NewApp(ctx context.Context, cfg *Cfg) *App {
 		started:                  time.Now(),
 		commit:                   string(cfg.Commit),
 		clients:                  make(map[string]*client),
+		ui:                       uiHandler(),
+		tailTokenKey:             make([]byte, 32),
+	}
+	_, err := rand.Read(app.tailTokenKey)
+	if err != nil {
+		panic(fmt.Sprintf("err generating tail token key: %v", err))
+	}
+	if cfg.Secrets != nil {
+		app.writeTokens = append(app.writeTokens, cfg.Secrets.Write)
+		app.readTokens = append(app.readTokens, cfg.Secrets.Read)
 	}
 	go app.cleanupClients()
 	go app.measureStatus()
@@ -66,7 +92,12 @@ func (app *App) serve(ctx context.Context) {
 	mux.Handle("/", app.rateLimitMiddleware(
 		app.corsMiddleware(
 			http.HandlerFunc(app.handleRequest))))
-	server := &http.Server{Addr: app.laddrPort, Handler: mux}
+	server := &http.Server{
+		Addr:    app.laddrPort,
+		Handler: mux,
+		// end tails on shutdown
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
 	go func() {
 		if app.tlsCertFname != "" {
 			fmt.Println("app listening https on", app.laddrPort)
@@ -90,13 +121,39 @@ func (app *App) handleRequest(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
+	if strings.HasPrefix(r.URL.Path, "/ui/") {
+		app.ui.ServeHTTP(w, r)
+		return
+	}
+	switch r.URL.Path {
+	case "/ui":
+		http.Redirect(w, r, "/ui/", http.StatusMovedPermanently)
+		return
+	case "/write":
+		app.handleWrite(w, r)
+		return
+	case "/query":
+		app.handleQuery(w, r)
+		return
+	case "/tail":
+		app.handleTail(w, r)
+		return
+	case "/tail/token":
+		app.handleTailToken(w, r)
+		return
+	case "/metrics":
+		app.handleMetrics(w)
+		return
+	}
 	app.handleStatus(w)
 }
 
 func (app *App) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", app.accessControlAllowOrigin)
-		w.Header().Set("Access-Control-Allow-Methods", "GET")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+		w.Header().Set("Access-Control-Expose-Headers", cursorTrailer)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/intob/logd/udp"
+)
+
+// handleMetrics writes the metrics in prometheus text format
+func (app *App) handleMetrics(w http.ResponseWriter) {
+	var writes map[string]uint64
+	if app.logStore != nil {
+		writes = app.logStore.WritesByRing()
+	}
+	var m *udp.Metrics
+	if app.udpSvc != nil {
+		m = app.udpSvc.Metrics()
+	}
+	buf := &bytes.Buffer{}
+	writeMetrics(buf, writes, m)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+func writeMetrics(w io.Writer, writes map[string]uint64, m *udp.Metrics) {
+	if writes != nil {
+		metricHeader(w, "logd_writes_total", "counter", "Msgs written, by ring.")
+		for _, ring := range sortedKeys(writes) {
+			fmt.Fprintf(w, "logd_writes_total{ring=\"%s\"} %d\n", escapeLabel(ring), writes[ring])
+		}
+	}
+	if m == nil {
+		return
+	}
+	metricHeader(w, "logd_rejected_packets_total", "counter", "Udp packets rejected, by reason.")
+	for _, reason := range sortedKeys(m.Rejected) {
+		fmt.Fprintf(w, "logd_rejected_packets_total{reason=\"%s\"} %d\n", reason, m.Rejected[reason])
+	}
+	metricHeader(w, "logd_tails", "gauge", "Active tails, by transport.")
+	fmt.Fprintf(w, "logd_tails{transport=\"udp\"} %d\n", m.Tails)
+	fmt.Fprintf(w, "logd_tails{transport=\"http\"} %d\n", m.Subscriptions)
+	metricHeader(w, "logd_tail_dropped_msgs_total", "counter", "Msgs dropped by tails that fell behind, by transport.")
+	for _, transport := range sortedKeys(m.TailDrops) {
+		fmt.Fprintf(w, "logd_tail_dropped_msgs_total{transport=\"%s\"} %d\n", transport, m.TailDrops[transport])
+	}
+	metricHeader(w, "logd_query_duration_seconds", "histogram", "Query latency, by transport.")
+	for _, transport := range sortedKeys(m.Queries) {
+		h := m.Queries[transport]
+		for i, bound := range h.Bounds {
+			fmt.Fprintf(w, "logd_query_duration_seconds_bucket{transport=\"%s\",le=\"%s\"} %d\n",
+				transport, strconv.FormatFloat(bound, 'g', -1, 64), h.Counts[i])
+		}
+		fmt.Fprintf(w, "logd_query_duration_seconds_bucket{transport=\"%s\",le=\"+Inf\"} %d\n", transport, h.Count)
+		fmt.Fprintf(w, "logd_query_duration_seconds_sum{transport=\"%s\"} %s\n",
+			transport, strconv.FormatFloat(h.Sum, 'g', -1, 64))
+		fmt.Fprintf(w, "logd_query_duration_seconds_count{transport=\"%s\"} %d\n", transport, h.Count)
+	}
+	metricHeader(w, "logd_udp_received_bytes_total", "counter", "Bytes received over udp.")
+	fmt.Fprintf(w, "logd_udp_received_bytes_total %d\n", m.BytesIn)
+	metricHeader(w, "logd_udp_sent_bytes_total", "counter", "Bytes sent over udp.")
+	fmt.Fprintf(w, "logd_udp_sent_bytes_total %d\n", m.BytesOut)
+	metricHeader(w, "logd_guard_filter_entries", "gauge", "Packet sums in the replay filter.")
+	fmt.Fprintf(w, "logd_guard_filter_entries %d\n", m.FilterLen)
+	metricHeader(w, "logd_guard_filter_capacity", "gauge", "Capacity of the replay filter.")
+	fmt.Fprintf(w, "logd_guard_filter_capacity %d\n", m.FilterCap)
+}
+
+func metricHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/intob/logd/udp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writeMetrics(buf, map[string]uint64{"/b/x": 2, "/a/\"q\"": 1}, &udp.Metrics{
+		BytesIn:   10,
+		BytesOut:  20,
+		Rejected:  map[string]uint64{"replay": 3, "unpack": 1},
+		Tails:     1,
+		TailDrops: map[string]uint64{"http": 4, "udp": 7},
+		Queries: map[string]*udp.Histogram{
+			"udp": {Bounds: []float64{.5, 1}, Counts: []uint64{1, 2}, Count: 3, Sum: 4.25},
+		},
+		FilterLen: 5,
+		FilterCap: 100,
+	})
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE logd_writes_total counter\nlogd_writes_total{ring=\"/a/\\\"q\\\"\"} 1\nlogd_writes_total{ring=\"/b/x\"} 2\n",
+		"logd_rejected_packets_total{reason=\"replay\"} 3\nlogd_rejected_packets_total{reason=\"unpack\"} 1\n",
+		"logd_tails{transport=\"udp\"} 1\nlogd_tails{transport=\"http\"} 0\n",
+		"logd_tail_dropped_msgs_total{transport=\"http\"} 4\nlogd_tail_dropped_msgs_total{transport=\"udp\"} 7\n",
+		"logd_query_duration_seconds_bucket{transport=\"udp\",le=\"0.5\"} 1\n",
+		"logd_query_duration_seconds_bucket{transport=\"udp\",le=\"+Inf\"} 3\n",
+		"logd_query_duration_seconds_sum{transport=\"udp\"} 4.25\n",
+		"logd_query_duration_seconds_count{transport=\"udp\"} 3\n",
+		"logd_udp_received_bytes_total 10\n",
+		"logd_udp_sent_bytes_total 20\n",
+		"logd_guard_filter_entries 5\n",
+		"logd_guard_filter_capacity 100\n",
+	} {
+		require.Contains(t, out, line)
+	}
+}
@@ -0,0 +1,249 @@
+package app
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/store"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	cursorTrailer    = "Logd-Cursor"
+	ndjsonFlushEvery = 64 // msgs
+)
+
+// queryPage is the json response to a query
+type queryPage struct {
+	Msgs   []json.RawMessage `json:"msgs"`
+	Cursor string            `json:"cursor"` // of the next page
+}
+
+// handleQuery reads msgs matching the url params, authenticated by
+// the read secret as bearer token. Msgs are streamed as ndjson if
+// asked for, with the cursor of the next page as a trailer,
+// or else returned as a json page.
+func (app *App) handleQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if app.udpSvc == nil {
+		http.Error(w, "querying is not enabled", http.StatusNotFound)
+		return
+	}
+	if !authorized(r, app.readTokens) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	start := time.Now()
+	params, err := parseQueryParams(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := app.udpSvc.Query(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer res.Drain() // if writing stopped early
+	defer func() {
+		app.udpSvc.ObserveQuery("http", time.Since(start))
+	}()
+	ndjson := r.URL.Query().Get("format") == "ndjson" ||
+		strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
+	if ndjson {
+		writeNdjson(w, r, res)
+		return
+	}
+	writePage(w, res)
+}
+
+// writePage writes the msgs of res as a json page
+func writePage(w http.ResponseWriter, res *store.Result) {
+	page := &queryPage{Msgs: make([]json.RawMessage, 0)}
+	for entry := range res.Entries {
+		entry.Msg.Seq = entry.Seq
+		data, err := protojson.Marshal(entry.Msg)
+		if err != nil {
+			fmt.Println("err marshaling msg:", err)
+			continue
+		}
+		page.Msgs = append(page.Msgs, data)
+	}
+	page.Cursor = base64.RawURLEncoding.EncodeToString(res.Cursor().Bytes())
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(page)
+	if err != nil {
+		fmt.Println("err writing page:", err)
+	}
+}
+
+// writeNdjson streams the msgs of res as ndjson, flushing every
+// ndjsonFlushEvery msgs, with the cursor of the next page as a
+// trailer. It stops early if a write fails, or the client is gone.
+func writeNdjson(w http.ResponseWriter, r *http.Request, res *store.Result) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Trailer", cursorTrailer)
+	flusher, _ := w.(http.Flusher)
+	var n int
+	for entry := range res.Entries {
+		select {
+		case <-r.Context().Done():
+			return
+		default:
+		}
+		entry.Msg.Seq = entry.Seq
+		data, err := protojson.Marshal(entry.Msg)
+		if err != nil {
+			fmt.Println("err marshaling msg:", err)
+			continue
+		}
+		_, err = w.Write(append(data, '\n'))
+		if err != nil {
+			fmt.Println("err writing msg:", err)
+			return
+		}
+		n++
+		if flusher != nil && n%ndjsonFlushEvery == 0 {
+			flusher.Flush()
+		}
+	}
+	w.Header().Set(cursorTrailer, base64.RawURLEncoding.EncodeToString(res.Cursor().Bytes()))
+}
+
+// parseQueryParams parses url params named as the json fields of
+// cmd.QueryParams. Attribute filters are given as attr.key=value,
+// attr.key.min=n and attr.key.max=n. Values that parse as a bool
+// or number are compared as such, unless quoted.
+func parseQueryParams(v url.Values) (*cmd.QueryParams, error) {
+	q := &cmd.QueryParams{}
+	var err error
+	for name, values := range v {
+		if len(values) > 1 {
+			return nil, fmt.Errorf("param %s is repeated", name)
+		}
+		value := values[0]
+		switch name {
+		case "offset":
+			q.Offset, err = parseUint32(value)
+		case "limit":
+			q.Limit, err = parseUint32(value)
+		case "tStart":
+			q.TStart, err = parseTime(value)
+		case "tEnd":
+			q.TEnd, err = parseTime(value)
+		case "txt":
+			q.Txt = proto.String(value)
+		case "txtIgnoreCase":
+			var b bool
+			b, err = strconv.ParseBool(value)
+			q.TxtIgnoreCase = proto.Bool(b)
+		case "txtRegex":
+			q.TxtRegex = proto.String(value)
+		case "lvl":
+			lvl, ok := cmd.Lvl_value[strings.ToUpper(value)]
+			if !ok {
+				err = fmt.Errorf("unknown lvl %q", value)
+			}
+			q.Lvl = cmd.Lvl(lvl).Enum()
+		case "keyPrefix":
+			q.KeyPrefix = proto.String(value)
+		case "cursor":
+			q.Cursor, err = base64.RawURLEncoding.DecodeString(value)
+		case "order":
+			order, ok := cmd.Order_value[strings.ToUpper(value)]
+			if !ok {
+				err = fmt.Errorf("unknown order %q", value)
+			}
+			q.Order = cmd.Order(order).Enum()
+		case "format":
+		default:
+			if !strings.HasPrefix(name, "attr.") {
+				return nil, fmt.Errorf("unknown param %q", name)
+			}
+			q.Attrs, err = parseAttrFilter(q.Attrs, strings.TrimPrefix(name, "attr."), value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return q, nil
+}
+
+// parseAttrFilter adds the filter to that of the same key, if any
+func parseAttrFilter(filters []*cmd.AttrFilter, name, value string) ([]*cmd.AttrFilter, error) {
+	key, bound := name, ""
+	if k, ok := strings.CutSuffix(name, ".min"); ok {
+		key, bound = k, "min"
+	} else if k, ok := strings.CutSuffix(name, ".max"); ok {
+		key, bound = k, "max"
+	}
+	var f *cmd.AttrFilter
+	for _, existing := range filters {
+		if existing.Key == key {
+			f = existing
+		}
+	}
+	if f == nil {
+		f = &cmd.AttrFilter{Key: key}
+		filters = append(filters, f)
+	}
+	if bound == "" {
+		f.Eq = parseAttr(value)
+		return filters, nil
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	if bound == "min" {
+		f.Min = proto.Float64(n)
+	} else {
+		f.Max = proto.Float64(n)
+	}
+	return filters, nil
+}
+
+func parseAttr(value string) *cmd.Attr {
+	if s, err := strconv.Unquote(value); err == nil {
+		return &cmd.Attr{Value: &cmd.Attr_Str{Str: s}}
+	}
+	if b, err := strconv.ParseBool(value); err == nil {
+		return &cmd.Attr{Value: &cmd.Attr_Bool{Bool: b}}
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return &cmd.Attr{Value: &cmd.Attr_Int{Int: n}}
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return &cmd.Attr{Value: &cmd.Attr_Float{Float: n}}
+	}
+	return &cmd.Attr{Value: &cmd.Attr_Str{Str: value}}
+}
+
+func parseUint32(value string) (*uint32, error) {
+	n, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Uint32(uint32(n)), nil
+}
+
+func parseTime(value string) (*timestamppb.Timestamp, error) {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, err
+	}
+	return timestamppb.New(t), nil
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/store"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseQueryParams(t *testing.T) {
+	v, err := url.ParseQuery("limit=10&tStart=2024-01-02T03:04:05Z&txt=err&txtIgnoreCase=true" +
+		"&lvl=warn&keyPrefix=app&order=oldest_first&cursor=AQI" +
+		"&attr.status=500&attr.user=%2242%22&attr.ms.min=1.5&attr.ms.max=20")
+	require.NoError(t, err)
+	q, err := parseQueryParams(v)
+	require.NoError(t, err)
+	require.Equal(t, uint32(10), q.GetLimit())
+	require.Equal(t, int64(1704164645), q.GetTStart().GetSeconds())
+	require.Equal(t, "err", q.GetTxt())
+	require.True(t, q.GetTxtIgnoreCase())
+	require.Equal(t, cmd.Lvl_WARN, q.GetLvl())
+	require.Equal(t, "app", q.GetKeyPrefix())
+	require.Equal(t, cmd.Order_OLDEST_FIRST, q.GetOrder())
+	require.Equal(t, []byte{1, 2}, q.GetCursor())
+	attrs := make(map[string]*cmd.AttrFilter)
+	for _, f := range q.Attrs {
+		attrs[f.Key] = f
+	}
+	require.Len(t, attrs, 3)
+	require.Equal(t, int64(500), attrs["status"].GetEq().GetInt())
+	require.Equal(t, "42", attrs["user"].GetEq().GetStr())
+	require.Equal(t, 1.5, attrs["ms"].GetMin())
+	require.Equal(t, 20.0, attrs["ms"].GetMax())
+
+	for _, bad := range []string{"limit=-1", "lvl=loud", "order=up", "tEnd=yesterday", "nope=1", "attr.ms.min=x", "limit=1&limit=2", "token=abc"} {
+		v, err := url.ParseQuery(bad)
+		require.NoError(t, err)
+		_, err = parseQueryParams(v)
+		require.Error(t, err, bad)
+	}
+}
+
+// failingWriter fails each write after the first n
+type failingWriter struct {
+	http.ResponseWriter
+	n, writes int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes > w.n {
+		return 0, errors.New("gone")
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func TestWriteNdjsonStopsEarly(t *testing.T) {
+	s, err := store.NewStore(&store.Cfg{FallbackSize: 100})
+	require.NoError(t, err)
+	defer s.Close()
+	for i := 0; i < 10; i++ {
+		data, err := proto.Marshal(&cmd.Msg{Key: "/test/app", Txt: fmt.Sprintf("msg %d", i)})
+		require.NoError(t, err)
+		require.NoError(t, s.Write("/test/app", time.Now(), data))
+	}
+	read := func() *store.Result {
+		return s.Read(&store.Query{KeyPrefix: "/test/app", Limit: 10, OldestFirst: true})
+	}
+
+	rec := httptest.NewRecorder()
+	writeNdjson(rec, httptest.NewRequest("GET", "/query", nil), read())
+	require.Len(t, strings.Split(strings.TrimSpace(rec.Body.String()), "\n"), 10)
+	require.NotEmpty(t, rec.Header().Get(cursorTrailer))
+
+	w := &failingWriter{ResponseWriter: httptest.NewRecorder(), n: 3}
+	res := read()
+	writeNdjson(w, httptest.NewRequest("GET", "/query", nil), res)
+	res.Drain()
+	require.Equal(t, 4, w.writes)
+	require.Empty(t, w.Header().Get(cursorTrailer))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec = httptest.NewRecorder()
+	res = read()
+	writeNdjson(rec, httptest.NewRequest("GET", "/query", nil).WithContext(ctx), res)
+	res.Drain()
+	require.Empty(t, rec.Body.String())
+}
@@ -10,15 +10,23 @@ import (
 	"time"
 
 	"github.com/intob/jfmt"
+	"github.com/intob/logd/store"
 )
 
 type Status struct {
-	Commit   string     `json:"commit"`
-	Uptime   string     `json:"uptime"`
-	NCpu     int        `json:"ncpu"`
-	MemAlloc uint64     `json:"mem_alloc"`
-	MemSys   uint64     `json:"mem_sys"`
-	Store    *StoreInfo `json:"store"`
+	Commit      string           `json:"commit"`
+	Uptime      string           `json:"uptime"`
+	NCpu        int              `json:"ncpu"`
+	MemAlloc    uint64           `json:"mem_alloc"`
+	MemSys      uint64           `json:"mem_sys"`
+	Store       *StoreInfo       `json:"store"`
+	Compression *CompressionInfo `json:"compression,omitempty"`
+}
+
+// CompressionInfo is the ratio of raw to compressed bytes
+type CompressionInfo struct {
+	CmdRatio   float64 `json:"cmd_ratio,omitempty"`
+	ReplyRatio float64 `json:"reply_ratio,omitempty"`
 }
 
 type StoreInfo struct {
@@ -28,9 +36,32 @@ type StoreInfo struct {
 }
 
 type RingInfo struct {
-	Key  string `json:"key"`
-	Head uint32 `json:"head"`
-	Size uint32 `json:"size"`
+	Key       string     `json:"key"`
+	Head      uint32     `json:"head"`
+	Size      uint32     `json:"size"`
+	Oldest    *time.Time `json:"oldest,omitempty"`
+	Newest    *time.Time `json:"newest,omitempty"`
+	Window    string     `json:"window,omitempty"`
+	DiskBytes int64      `json:"disk_bytes,omitempty"`
+	MaxAge    string     `json:"max_age,omitempty"`
+	MaxBytes  int64      `json:"max_bytes,omitempty"`
+}
+
+// setWindow sets the effective retention window of the ring
+func setWindow(info *RingInfo, w *store.Window) {
+	info.DiskBytes = w.DiskBytes
+	if w.Retention != nil {
+		if w.Retention.MaxAge > 0 {
+			info.MaxAge = jfmt.FmtDuration(w.Retention.MaxAge)
+		}
+		info.MaxBytes = int64(w.Retention.MaxBytes)
+	}
+	if w.Oldest.IsZero() {
+		return
+	}
+	info.Oldest = &w.Oldest
+	info.Newest = &w.Newest
+	info.Window = jfmt.FmtDuration(w.Newest.Sub(w.Oldest))
 }
 
 func (app *App) handleStatus(w http.ResponseWriter) {
@@ -57,13 +88,18 @@ func (app *App) measureStatus() {
 		lastTime = time.Now()
 
 		headsAndSizes := app.logStore.HeadsAndSizes()
+		windows := app.logStore.Windows()
 		rings := make([]*RingInfo, 0, len(headsAndSizes))
 		for key := range headsAndSizes {
-			rings = append(rings, &RingInfo{
+			info := &RingInfo{
 				Key:  key,
 				Head: headsAndSizes[key][0],
 				Size: headsAndSizes[key][1],
-			})
+			}
+			if w := windows[key]; w != nil {
+				setWindow(info, w)
+			}
+			rings = append(rings, info)
 		}
 		sort.Slice(rings, func(i, j int) bool {
 			return rings[i].Key < rings[j].Key
@@ -85,6 +121,13 @@ func (app *App) measureStatus() {
 			},
 		}
 
+		if app.udpSvc != nil {
+			cmds, replies := app.udpSvc.CompressionRatios()
+			if cmds > 0 || replies > 0 {
+				info.Compression = &CompressionInfo{CmdRatio: cmds, ReplyRatio: replies}
+			}
+		}
+
 		data, err := json.Marshal(info)
 		if err != nil {
 			panic(fmt.Sprintf("failed to marshal json: %s", err))
@@ -0,0 +1,235 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/intob/logd/udp"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	tailKeepAlive    = 15 * time.Second
+	tailWriteTimeout = 10 * time.Second
+	tailTokenTtl     = time.Minute
+	wsProtocol       = "logd"
+	wsBearerPrefix   = "bearer." // of the websocket protocol carrying the token
+)
+
+// handleTail streams msgs as they are written, as server-sent events,
+// or over a websocket if requested. As browsers can't set headers
+// for either, see tailAuthorized for the other ways to authenticate.
+func (app *App) handleTail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if app.udpSvc == nil {
+		http.Error(w, "tailing is not enabled", http.StatusNotFound)
+		return
+	}
+	if !app.tailAuthorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	v := r.URL.Query()
+	v.Del("token") // of sse, checked by tailAuthorized
+	params, err := parseQueryParams(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sub, err := app.udpSvc.Subscribe(params)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer app.udpSvc.Unsubscribe(sub)
+	if websocket.IsWebSocketUpgrade(r) {
+		app.tailWebSocket(w, r, sub)
+		return
+	}
+	tailEvents(w, r, sub)
+}
+
+// tailEvents writes a msg event per msg, preceded by a gap
+// event if msgs were dropped because the client fell behind
+func tailEvents(w http.ResponseWriter, r *http.Request, sub *udp.Subscription) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	flusher.Flush()
+	keepAlive := time.NewTicker(tailKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case ev, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if ev.Dropped > 0 {
+				_, err = fmt.Fprintf(w, "event: gap\ndata: {\"dropped\":%d}\n\n", ev.Dropped)
+				if err != nil {
+					return
+				}
+			}
+			data, merr := protojson.Marshal(ev.Msg)
+			if merr != nil {
+				fmt.Println("err marshaling msg:", merr)
+				return
+			}
+			_, err = fmt.Fprintf(w, "event: msg\ndata: %s\n\n", data)
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// tailWebSocket writes a json text frame per msg, {"msg":{...}},
+// preceded by {"gap":{"dropped":n}} if msgs were dropped
+func (app *App) tailWebSocket(w http.ResponseWriter, r *http.Request, sub *udp.Subscription) {
+	upgrader := &websocket.Upgrader{CheckOrigin: app.checkOrigin, Subprotocols: []string{wsProtocol}}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // upgrader replied with the error
+	}
+	defer conn.Close()
+	closed := make(chan struct{})
+	go func() {
+		// read to handle control frames, until the client closes
+		defer close(closed)
+		for {
+			_, _, err := conn.NextReader()
+			if err != nil {
+				return
+			}
+		}
+	}()
+	keepAlive := time.NewTicker(tailKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, ""),
+				time.Now().Add(time.Second))
+			return
+		case <-closed:
+			return
+		case <-keepAlive.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(tailWriteTimeout))
+		case ev, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(tailWriteTimeout))
+			if ev.Dropped > 0 {
+				err = conn.WriteMessage(websocket.TextMessage,
+					[]byte(fmt.Sprintf("{\"gap\":{\"dropped\":%d}}", ev.Dropped)))
+				if err != nil {
+					return
+				}
+			}
+			data, merr := protojson.Marshal(ev.Msg)
+			if merr != nil {
+				fmt.Println("err marshaling msg:", merr)
+				return
+			}
+			err = conn.WriteMessage(websocket.TextMessage,
+				[]byte(fmt.Sprintf("{\"msg\":%s}", data)))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// checkOrigin allows websockets from the allowed origin
+func (app *App) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || app.accessControlAllowOrigin == "*" ||
+		origin == app.accessControlAllowOrigin
+}
+
+// tailAuthorized returns true if the bearer token is a read token, or is
+// offered as the websocket protocol bearer.<token as base64url>, or if
+// the token param was minted by handleTailToken. Unlike the read secret,
+// a minted token may be put in the url, as it soon expires.
+func (app *App) tailAuthorized(r *http.Request) bool {
+	if authorized(r, app.readTokens) {
+		return true
+	}
+	for _, p := range websocket.Subprotocols(r) {
+		encoded, ok := strings.CutPrefix(p, wsBearerPrefix)
+		if !ok {
+			continue
+		}
+		token, err := base64.RawURLEncoding.DecodeString(encoded)
+		if err == nil && validToken(string(token), app.readTokens) {
+			return true
+		}
+	}
+	return validTailToken(app.tailTokenKey, r.URL.Query().Get("token"), time.Now())
+}
+
+// handleTailToken mints a token to tail over sse with, as the token
+// param, for tailTokenTtl, authenticated by read token
+func (app *App) handleTailToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r, app.readTokens) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	expires := time.Now().Add(tailTokenTtl)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	fmt.Fprintf(w, "{\"token\":%q,\"expires\":%q}\n",
+		mintTailToken(app.tailTokenKey, expires), expires.Format(time.RFC3339))
+}
+
+// mintTailToken returns the expiry, as unix seconds, & its hmac by key
+func mintTailToken(key []byte, expires time.Time) string {
+	exp := binary.BigEndian.AppendUint64(nil, uint64(expires.Unix()))
+	mac := hmac.New(sha256.New, key)
+	mac.Write(exp)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(exp))
+}
+
+// validTailToken returns true if token was minted by key, and has not expired
+func validTailToken(key []byte, token string, now time.Time) bool {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != 8+sha256.Size {
+		return false
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(raw[:8])
+	if !hmac.Equal(mac.Sum(nil), raw[8:]) {
+		return false
+	}
+	return now.Unix() < int64(binary.BigEndian.Uint64(raw[:8]))
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/udp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTailEvents(t *testing.T) {
+	c := make(chan *udp.Event, 2)
+	c <- &udp.Event{Msg: &cmd.Msg{Key: "/a/b", Txt: "one"}}
+	c <- &udp.Event{Msg: &cmd.Msg{Key: "/a/b", Txt: "two"}, Dropped: 3}
+	close(c)
+	w := httptest.NewRecorder()
+	tailEvents(w, httptest.NewRequest("GET", "/tail", nil), &udp.Subscription{C: c})
+	require.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	events := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+	require.Len(t, events, 3)
+	require.True(t, strings.HasPrefix(events[0], "event: msg\ndata: {"))
+	require.Contains(t, events[0], "one")
+	require.Equal(t, "event: gap\ndata: {\"dropped\":3}", events[1])
+	require.Contains(t, events[2], "two")
+}
+
+func TestTailAuthorized(t *testing.T) {
+	app := &App{readTokens: []string{"secret"}, tailTokenKey: []byte("key")}
+	r := httptest.NewRequest("GET", "/tail?token=secret", nil)
+	require.False(t, app.tailAuthorized(r), "the secret may not be put in the url")
+
+	r = httptest.NewRequest("GET", "/tail", nil)
+	r.Header.Set("Sec-WebSocket-Protocol", "logd, bearer."+base64.RawURLEncoding.EncodeToString([]byte("secret")))
+	require.True(t, app.tailAuthorized(r))
+	r.Header.Set("Sec-WebSocket-Protocol", "logd, bearer."+base64.RawURLEncoding.EncodeToString([]byte("nope")))
+	require.False(t, app.tailAuthorized(r))
+
+	w := httptest.NewRecorder()
+	app.handleTailToken(w, httptest.NewRequest("POST", "/tail/token", nil))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/tail/token", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	app.handleTailToken(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	var minted struct{ Token string }
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &minted))
+	require.True(t, app.tailAuthorized(httptest.NewRequest("GET", "/tail?token="+minted.Token, nil)))
+
+	expired := mintTailToken(app.tailTokenKey, time.Now().Add(-time.Second))
+	require.False(t, validTailToken(app.tailTokenKey, expired, time.Now()))
+	require.False(t, validTailToken([]byte("other"), minted.Token, time.Now()))
+}
@@ -0,0 +1,23 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeUi(t *testing.T) {
+	app := &App{ui: uiHandler()}
+	w := httptest.NewRecorder()
+	app.handleRequest(w, httptest.NewRequest("GET", "/ui/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	require.Contains(t, w.Body.String(), "<title>logd</title>")
+
+	w = httptest.NewRecorder()
+	app.handleRequest(w, httptest.NewRequest("GET", "/ui", nil))
+	require.Equal(t, http.StatusMovedPermanently, w.Code)
+	require.Equal(t, "/ui/", w.Header().Get("Location"))
+}
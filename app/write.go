@@ -0,0 +1,148 @@
+package app
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/pkg"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const maxWriteBody = pkg.MaxDecompressedSize
+
+// handleWrite accepts a msg, or an array of msgs, as json, or a WRITE
+// cmd as protobuf, authenticated by bearer token. Msgs are written
+// as if received over udp, so they also reach tails.
+func (app *App) handleWrite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if app.udpSvc == nil {
+		http.Error(w, "writing is not enabled", http.StatusNotFound)
+		return
+	}
+	if !authorized(r, app.writeTokens) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWriteBody))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("err reading body: %v", err), http.StatusRequestEntityTooLarge)
+		return
+	}
+	msgs, err := parseMsgs(r.Header.Get("Content-Type"), r.Header.Get("Content-Encoding"), body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, msg := range msgs {
+		if msg.T == nil {
+			msg.T = timestamppb.Now()
+		}
+	}
+	err = app.udpSvc.Write(msgs...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "{\"accepted\":%d}\n", len(msgs))
+}
+
+// authorized returns true if the bearer token is one of tokens
+func authorized(r *http.Request, tokens []string) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && validToken(token, tokens)
+}
+
+// validToken returns true if token is one of tokens
+func validToken(token string, tokens []string) bool {
+	if token == "" {
+		return false
+	}
+	for _, t := range tokens {
+		if t != "" && subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMsgs parses a json msg or array of msgs, or a protobuf WRITE
+// cmd, that may be compressed by zstd or snappy, as its encoding says
+func parseMsgs(contentType, contentEncoding string, body []byte) ([]*cmd.Msg, error) {
+	var c cmd.Compression
+	switch contentEncoding {
+	case "", "identity":
+	case "zstd":
+		c = cmd.Compression_ZSTD
+	case "snappy":
+		c = cmd.Compression_SNAPPY
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", contentEncoding)
+	}
+	body, err := pkg.Decompress(c, body)
+	if err != nil {
+		return nil, fmt.Errorf("err decompressing body: %w", err)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/json", "":
+		return parseJsonMsgs(body)
+	case "application/protobuf", "application/x-protobuf":
+		c := &cmd.Cmd{}
+		err = proto.Unmarshal(body, c)
+		if err != nil {
+			return nil, fmt.Errorf("err unmarshaling cmd: %w", err)
+		}
+		if c.Name != cmd.Name_WRITE || c.Fragment != nil {
+			return nil, errors.New("cmd is not a write")
+		}
+		msgs := c.Msgs
+		if c.Msg != nil {
+			msgs = append([]*cmd.Msg{c.Msg}, msgs...)
+		}
+		return msgs, nil
+	}
+	return nil, fmt.Errorf("unsupported content type %q", mediaType)
+}
+
+func parseJsonMsgs(body []byte) ([]*cmd.Msg, error) {
+	body = bytes.TrimSpace(body)
+	if !bytes.HasPrefix(body, []byte("[")) {
+		msg := &cmd.Msg{}
+		err := protojson.Unmarshal(body, msg)
+		if err != nil {
+			return nil, fmt.Errorf("err unmarshaling msg: %w", err)
+		}
+		return []*cmd.Msg{msg}, nil
+	}
+	var raw []json.RawMessage
+	err := json.Unmarshal(body, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("err unmarshaling msgs: %w", err)
+	}
+	msgs := make([]*cmd.Msg, 0, len(raw))
+	for i, r := range raw {
+		msg := &cmd.Msg{}
+		err = protojson.Unmarshal(r, msg)
+		if err != nil {
+			return nil, fmt.Errorf("err unmarshaling msg %d: %w", i, err)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/pkg"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseMsgs(t *testing.T) {
+	msgs, err := parseMsgs("application/json", "", []byte(`{"key":"/test/app","lvl":"ERROR","txt":"boom","attrs":{"status":{"int":"503"}}}`))
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	require.Equal(t, cmd.Lvl_ERROR, msgs[0].Lvl)
+	require.Equal(t, int64(503), msgs[0].Attrs["status"].GetInt())
+
+	msgs, err = parseMsgs("application/json; charset=utf-8", "", []byte(` [{"key":"/test/app","txt":"a"},{"key":"/test/app","txt":"b","t":"2024-01-02T03:04:05Z"}]`))
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	require.Equal(t, int64(1704164645), msgs[1].T.Seconds)
+
+	payload, err := proto.Marshal(&cmd.Cmd{
+		Name: cmd.Name_WRITE,
+		Msg:  &cmd.Msg{Txt: "a"},
+		Msgs: []*cmd.Msg{{Txt: "b"}, {Txt: "c"}},
+	})
+	require.NoError(t, err)
+	payload, err = pkg.Compress(cmd.Compression_SNAPPY, payload)
+	require.NoError(t, err)
+	msgs, err = parseMsgs("application/x-protobuf", "snappy", payload)
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+	require.Equal(t, "a", msgs[0].Txt)
+
+	_, err = parseMsgs("application/json", "", []byte(`{"nope":1}`))
+	require.Error(t, err)
+	_, err = parseMsgs("text/plain", "", []byte(`hi`))
+	require.Error(t, err)
+	_, err = parseMsgs("application/json", "gzip", []byte(`{}`))
+	require.Error(t, err)
+	payload, _ = proto.Marshal(&cmd.Cmd{Name: cmd.Name_QUERY})
+	_, err = parseMsgs("application/protobuf", "", payload)
+	require.Error(t, err)
+}
+
+func TestAuthorized(t *testing.T) {
+	tokens := []string{"token", ""}
+	r := httptest.NewRequest("POST", "/write", nil)
+	require.False(t, authorized(r, tokens))
+	r.Header.Set("Authorization", "Bearer ")
+	require.False(t, authorized(r, tokens))
+	r.Header.Set("Authorization", "Bearer nope")
+	require.False(t, authorized(r, tokens))
+	r.Header.Set("Authorization", "Bearer token")
+	require.True(t, authorized(r, tokens))
+}
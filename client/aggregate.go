@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/udp"
+)
+
+const (
+	aggregateTimeout  = time.Second // without a reply, before asking again
+	aggregateAttempts = 3
+)
+
+// Aggregate counts the msgs matching q, grouped as asked by agg.
+// As the result is small, it is asked for again if lost.
+func (cl *Client) Aggregate(ctx context.Context, q *cmd.QueryParams, agg *cmd.Aggregation, secret []byte) (*cmd.AggregateResult, error) {
+	id, replies := cl.request()
+	defer cl.done(id)
+	c := &cmd.Cmd{
+		Name:        cmd.Name_AGGREGATE,
+		Id:          id,
+		QueryParams: q,
+		Aggregation: agg,
+	}
+	fragments := make(map[uint64][][]byte) // parts, by fragment id
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	attempts := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			if attempts == aggregateAttempts {
+				return nil, errors.New("no reply to aggregate")
+			}
+			attempts++
+			// signed each time, as logd rejects replayed packets
+			signed, err := cl.SignCmd(ctx, c, secret)
+			if err == nil {
+				err = cl.Wait(ctx)
+			}
+			if err == nil {
+				err = cl.Write(signed)
+			}
+			if err != nil {
+				return nil, err
+			}
+			timer.Reset(aggregateTimeout)
+		case reply := <-replies:
+			timer.Reset(aggregateTimeout)
+			if f := reply.Fragment; f != nil {
+				var err error
+				reply, err = reassembleReply(fragments, f)
+				if err != nil {
+					return nil, err
+				}
+				if reply == nil {
+					continue
+				}
+			}
+			if reply.Err != "" {
+				return nil, fmt.Errorf("aggregate failed: %s", reply.Err)
+			}
+			if reply.Aggregate != nil {
+				return reply.Aggregate, nil
+			}
+		}
+	}
+}
+
+// reassembleReply buffers the fragment, and returns the reply once complete
+func reassembleReply(fragments map[uint64][][]byte, f *cmd.Fragment) (*cmd.Reply, error) {
+	if f.Total == 0 || f.Total > udp.MaxFragments || f.Index >= f.Total {
+		return nil, fmt.Errorf("invalid fragment %d of %d", f.Index, f.Total)
+	}
+	parts, ok := fragments[f.Id]
+	if !ok {
+		parts = make([][]byte, f.Total)
+		fragments[f.Id] = parts
+	}
+	if uint32(len(parts)) != f.Total {
+		return nil, errors.New("fragment total changed")
+	}
+	parts[f.Index] = f.Data
+	payload := make([]byte, 0)
+	for _, part := range parts {
+		if part == nil {
+			return nil, nil
+		}
+		payload = append(payload, part...)
+	}
+	delete(fragments, f.Id)
+	reply, err := unpackReply(payload)
+	if err != nil {
+		return nil, fmt.Errorf("err unpacking reassembled reply: %w", err)
+	}
+	return reply, nil
+}
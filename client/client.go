@@ -2,14 +2,21 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/intob/logd/cmd"
 	"github.com/intob/logd/pkg"
+	"github.com/intob/logd/udp"
 	"golang.org/x/time/rate"
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -17,6 +24,12 @@ type Client struct {
 	conn             net.Conn
 	rateLimiter      *rate.Limiter
 	packetBufferSize int
+	compression      cmd.Compression
+	queryWindow      uint32
+	nextId           atomic.Uint64 // of requests
+	routeOnce        sync.Once
+	mu               sync.Mutex
+	requests         map[uint64]chan *cmd.Reply // by id
 }
 
 type Cfg struct {
@@ -25,9 +38,26 @@ type Cfg struct {
 	PacketBufferSize int           `yaml:"packet_buffer_size"`
 	RateLimitEvery   time.Duration `yaml:"ratelimit_every"`
 	RateLimitBurst   int           `yaml:"ratelimit_burst"`
+	Compression      string        `yaml:"compression"`  // of cmds, zstd or snappy
+	QueryWindow      uint32        `yaml:"query_window"` // query replies in flight
 }
 
+// fragmentOverhead is the most that a cmd adds to a fragment of data
+const fragmentOverhead = 48
+
+// maxPackRatio bounds the msgs packed into a compressed
+// packet, before compressing to see if they fit
+const maxPackRatio = 8
+
 func NewClient(cfg *Cfg) (*Client, error) {
+	compression := cmd.Compression_NONE
+	if cfg.Compression != "" {
+		c, ok := cmd.Compression_value[strings.ToUpper(cfg.Compression)]
+		if !ok {
+			return nil, fmt.Errorf("unknown compression %q", cfg.Compression)
+		}
+		compression = cmd.Compression(c)
+	}
 	var ip string
 	if parsed := net.ParseIP(cfg.Host); parsed != nil {
 		ip = fmt.Sprintf("[%s]", parsed.String())
@@ -52,15 +82,48 @@ func NewClient(cfg *Cfg) (*Client, error) {
 			rate.Every(cfg.RateLimitEvery),
 			cfg.RateLimitBurst)
 	}
-	return &Client{conn, rateLimiter, cfg.PacketBufferSize}, nil
+	queryWindow := cfg.QueryWindow
+	if queryWindow == 0 {
+		queryWindow = defaultQueryWindow
+	}
+	return &Client{
+		conn:             conn,
+		rateLimiter:      rateLimiter,
+		packetBufferSize: cfg.PacketBufferSize,
+		compression:      compression,
+		queryWindow:      queryWindow,
+		requests:         make(map[uint64]chan *cmd.Reply),
+	}, nil
 }
 
 func (cl *Client) SignCmd(ctx context.Context, command *cmd.Cmd, secret []byte) ([]byte, error) {
+	payload, err := cl.marshalCmd(command)
+	if err != nil {
+		return nil, err
+	}
+	return pkg.SignCompressed(secret, cl.compression, payload), nil
+}
+
+// marshalCmd returns the payload of the cmd, compressed if configured
+func (cl *Client) marshalCmd(command *cmd.Cmd) ([]byte, error) {
 	payload, err := proto.Marshal(command)
 	if err != nil {
 		return nil, fmt.Errorf("err marshalling cmd: %w", err)
 	}
-	return pkg.Sign(secret, payload), nil
+	payload, err = pkg.Compress(cl.compression, payload)
+	if err != nil {
+		return nil, fmt.Errorf("err compressing cmd: %w", err)
+	}
+	return payload, nil
+}
+
+// headerSize returns the size of a packet before the payload of a
+// cmd, including the flag byte that a compressed payload begins with
+func (cl *Client) headerSize() int {
+	if cl.compression == cmd.Compression_NONE {
+		return pkg.HeaderSize
+	}
+	return pkg.HeaderSize + 1
 }
 
 func (cl *Client) Wait(ctx context.Context) error {
@@ -70,6 +133,96 @@ func (cl *Client) Wait(ctx context.Context) error {
 	return nil
 }
 
+// WriteMsgs signs & writes msgs, packing as many into each
+// packet as fit in the packet buffer size
+func (cl *Client) WriteMsgs(ctx context.Context, msgs []*cmd.Msg, secret []byte) error {
+	limit := cl.packetBufferSize - cl.headerSize()
+	if cl.compression != cmd.Compression_NONE {
+		limit *= maxPackRatio
+	}
+	for len(msgs) > 0 {
+		n, size := 1, msgSize(msgs[0])
+		for n < len(msgs) && size+msgSize(msgs[n]) <= limit {
+			size += msgSize(msgs[n])
+			n++
+		}
+		var payload []byte
+		for {
+			c := &cmd.Cmd{Name: cmd.Name_WRITE, Msgs: msgs[:n]}
+			if n == 1 {
+				c = &cmd.Cmd{Name: cmd.Name_WRITE, Msg: msgs[0]}
+			}
+			var err error
+			payload, err = cl.marshalCmd(c)
+			if err != nil {
+				return err
+			}
+			// compressed, the msgs may not fit after all
+			if cl.headerSize()+len(payload) <= cl.packetBufferSize || n == 1 {
+				break
+			}
+			n /= 2
+		}
+		msgs = msgs[n:]
+		err := cl.writePayload(ctx, payload, secret)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePayload signs & writes the payload of a WRITE,
+// in fragments if it does not fit in one packet
+func (cl *Client) writePayload(ctx context.Context, payload, secret []byte) error {
+	if cl.headerSize()+len(payload) <= cl.packetBufferSize {
+		return cl.writeSigned(ctx, pkg.SignCompressed(secret, cl.compression, payload))
+	}
+	chunk := cl.packetBufferSize - pkg.HeaderSize - fragmentOverhead
+	if chunk <= 0 {
+		return errors.New("packet buffer size too small to fragment")
+	}
+	total := (len(payload) + chunk - 1) / chunk
+	if total > udp.MaxFragments {
+		return fmt.Errorf("payload of %d bytes is too large", len(payload))
+	}
+	id := rand.Uint64()
+	for i := 0; i < total; i++ {
+		data := payload[i*chunk : min((i+1)*chunk, len(payload))]
+		fragment, err := proto.Marshal(&cmd.Cmd{
+			Name: cmd.Name_WRITE,
+			Fragment: &cmd.Fragment{
+				Id:          id,
+				Index:       uint32(i),
+				Total:       uint32(total),
+				Data:        data,
+				Compression: cl.compression,
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("err marshalling fragment: %w", err)
+		}
+		err = cl.writeSigned(ctx, pkg.Sign(secret, fragment))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cl *Client) writeSigned(ctx context.Context, signed []byte) error {
+	err := cl.Wait(ctx)
+	if err != nil {
+		return err
+	}
+	return cl.Write(signed)
+}
+
+// msgSize returns the size of msg as an element of cmd.Cmd.Msgs
+func msgSize(msg *cmd.Msg) int {
+	return protowire.SizeTag(4) + protowire.SizeBytes(proto.Size(msg))
+}
+
 func (cl *Client) Write(signed []byte) error {
 	_, err := cl.conn.Write(signed)
 	if err != nil {
@@ -0,0 +1,439 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/pkg"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWriteMsgsPacks(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	cl, err := NewClient(&Cfg{
+		Host:             "127.0.0.1",
+		Port:             conn.LocalAddr().(*net.UDPAddr).Port,
+		PacketBufferSize: 1460,
+	})
+	require.NoError(t, err)
+	msgs := make([]*cmd.Msg, 0)
+	for i := 0; i < 100; i++ {
+		msgs = append(msgs, &cmd.Msg{Key: "/test/app", Txt: fmt.Sprintf("msg %d", i)})
+	}
+	msgs = append(msgs, &cmd.Msg{Key: "/test/app", Txt: strings.Repeat("x", 2000)})
+	require.NoError(t, cl.WriteMsgs(context.Background(), msgs, []byte("secret")))
+
+	buf := make([]byte, 4096)
+	var got []string
+	var packets int
+	var fragments []byte
+	for len(got) < len(msgs) {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		require.LessOrEqual(t, n, 1460)
+		packets++
+		p := &pkg.Pkg{}
+		require.NoError(t, pkg.Unpack(buf[:n], p))
+		c := &cmd.Cmd{}
+		require.NoError(t, proto.Unmarshal(p.Payload, c))
+		if c.Fragment != nil {
+			fragments = append(fragments, c.Fragment.Data...)
+			if c.Fragment.Index < c.Fragment.Total-1 {
+				continue
+			}
+			c = &cmd.Cmd{}
+			require.NoError(t, proto.Unmarshal(fragments, c))
+		}
+		if c.Msg != nil {
+			got = append(got, c.Msg.Txt)
+			continue
+		}
+		for _, m := range c.Msgs {
+			got = append(got, m.Txt)
+		}
+	}
+	for i, m := range msgs {
+		require.Equal(t, m.Txt, got[i])
+	}
+	// 100 msgs of ~20 bytes fit in 2 packets, the oversized one in 2 fragments
+	require.Equal(t, 4, packets)
+}
+
+func TestWriteMsgsCompressed(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	cl, err := NewClient(&Cfg{
+		Host:             "127.0.0.1",
+		Port:             conn.LocalAddr().(*net.UDPAddr).Port,
+		PacketBufferSize: 1460,
+		Compression:      "zstd",
+	})
+	require.NoError(t, err)
+	msgs := make([]*cmd.Msg, 0)
+	for i := 0; i < 100; i++ {
+		msgs = append(msgs, &cmd.Msg{Key: "/test/app", Txt: fmt.Sprintf(`{"msg":"served","status":200,"n":%d}`, i)})
+	}
+	require.NoError(t, cl.WriteMsgs(context.Background(), msgs, []byte("secret")))
+
+	buf := make([]byte, 4096)
+	var got int
+	var packets int
+	for got < len(msgs) {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		require.LessOrEqual(t, n, 1460)
+		packets++
+		p := &pkg.Pkg{}
+		require.NoError(t, pkg.Unpack(buf[:n], p))
+		compression, payload := pkg.Flagged(p.Payload)
+		require.Equal(t, cmd.Compression_ZSTD, compression)
+		payload, err = pkg.Decompress(compression, payload)
+		require.NoError(t, err)
+		c := &cmd.Cmd{}
+		require.NoError(t, proto.Unmarshal(payload, c))
+		got += len(c.Msgs)
+	}
+	// uncompressed, these would take 4 packets
+	require.Equal(t, 1, packets)
+	_, err = NewClient(&Cfg{Host: "127.0.0.1", Compression: "lz4"})
+	require.Error(t, err)
+}
+
+func TestWriteMsgsFragments(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	cl, err := NewClient(&Cfg{
+		Host:             "127.0.0.1",
+		Port:             conn.LocalAddr().(*net.UDPAddr).Port,
+		PacketBufferSize: 512,
+	})
+	require.NoError(t, err)
+	msg := &cmd.Msg{Key: "/test/app", Txt: strings.Repeat("stack trace line\n", 200)}
+	require.NoError(t, cl.WriteMsgs(context.Background(), []*cmd.Msg{msg}, []byte("secret")))
+
+	buf := make([]byte, 4096)
+	var payload []byte
+	for {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		n, _, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		require.LessOrEqual(t, n, 512)
+		p := &pkg.Pkg{}
+		require.NoError(t, pkg.Unpack(buf[:n], p))
+		c := &cmd.Cmd{}
+		require.NoError(t, proto.Unmarshal(p.Payload, c))
+		require.NotNil(t, c.Fragment)
+		payload = append(payload, c.Fragment.Data...)
+		if c.Fragment.Index == c.Fragment.Total-1 {
+			break
+		}
+	}
+	c := &cmd.Cmd{}
+	require.NoError(t, proto.Unmarshal(payload, c))
+	require.Equal(t, msg.Txt, c.Msg.Txt)
+}
+
+func TestQueryPageRecoversMissing(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	cl, err := NewClient(&Cfg{
+		Host:             "127.0.0.1",
+		Port:             conn.LocalAddr().(*net.UDPAddr).Port,
+		PacketBufferSize: 1460,
+	})
+	require.NoError(t, err)
+	type result struct {
+		page *Page
+		err  error
+	}
+	done := make(chan result)
+	go func() {
+		page, err := cl.QueryPage(context.Background(), &cmd.QueryParams{}, []byte("secret"))
+		done <- result{page, err}
+	}()
+
+	buf := make([]byte, 1460)
+	read := func() (*cmd.Cmd, net.Addr) {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		n, raddr, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		p := &pkg.Pkg{}
+		require.NoError(t, pkg.Unpack(buf[:n], p))
+		c := &cmd.Cmd{}
+		require.NoError(t, proto.Unmarshal(p.Payload, c))
+		return c, raddr
+	}
+	c, raddr := read()
+	require.Equal(t, cmd.Name_QUERY, c.Name)
+	require.Equal(t, uint32(defaultQueryWindow), c.QueryParams.GetWindow())
+	require.NotZero(t, c.Id)
+	replies := []*cmd.Reply{
+		{Id: c.Id, Seq: 0, Msgs: []*cmd.Msg{{Txt: "a"}, {Txt: "b"}}},
+		{Id: c.Id, Seq: 1, Msgs: []*cmd.Msg{{Txt: "c"}}},
+		{Id: c.Id, Seq: 2, Msgs: []*cmd.Msg{{Txt: "d"}}},
+		{Id: c.Id, Seq: 3, Total: proto.Uint32(3), Msgs: []*cmd.Msg{{Key: "//logd", Txt: "+END", Cursor: []byte{7}}}},
+	}
+	send := func(r *cmd.Reply) {
+		raw, err := proto.Marshal(r)
+		require.NoError(t, err)
+		packed, err := pkg.Pack(cmd.Compression_NONE, raw)
+		require.NoError(t, err)
+		_, err = conn.WriteTo(packed, raddr)
+		require.NoError(t, err)
+	}
+	// reply 1 is lost, until reported missing
+	send(replies[0])
+	send(replies[2])
+	send(replies[3])
+	for {
+		c, _ = read()
+		require.Equal(t, cmd.Name_ACK, c.Name)
+		require.Equal(t, replies[0].Id, c.Id)
+		if len(c.Ack.Missing) > 0 {
+			require.Equal(t, uint32(1), c.Ack.Next)
+			require.Equal(t, []uint32{1}, c.Ack.Missing)
+			break
+		}
+	}
+	send(replies[1])
+	for c.Ack.Next < 4 {
+		c, _ = read()
+	}
+	res := <-done
+	require.NoError(t, res.err)
+	var got []string
+	for _, m := range res.page.Msgs {
+		got = append(got, m.Txt)
+	}
+	require.Equal(t, []string{"a", "b", "c", "d"}, got)
+	require.Equal(t, []byte{7}, res.page.Cursor)
+}
+
+func TestQueryPageReturnsErr(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	cl, err := NewClient(&Cfg{
+		Host:             "127.0.0.1",
+		Port:             conn.LocalAddr().(*net.UDPAddr).Port,
+		PacketBufferSize: 1460,
+	})
+	require.NoError(t, err)
+	done := make(chan error)
+	go func() {
+		_, err := cl.QueryPage(context.Background(), &cmd.QueryParams{}, []byte("secret"))
+		done <- err
+	}()
+	buf := make([]byte, 1460)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, raddr, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	p := &pkg.Pkg{}
+	require.NoError(t, pkg.Unpack(buf[:n], p))
+	c := &cmd.Cmd{}
+	require.NoError(t, proto.Unmarshal(p.Payload, c))
+	raw, err := proto.Marshal(&cmd.Reply{Id: c.Id, Total: proto.Uint32(0), Err: "bad regex"})
+	require.NoError(t, err)
+	packed, err := pkg.Pack(cmd.Compression_NONE, raw)
+	require.NoError(t, err)
+	_, err = conn.WriteTo(packed, raddr)
+	require.NoError(t, err)
+	select {
+	case err = <-done:
+		require.ErrorContains(t, err, "bad regex")
+	case <-time.After(time.Second):
+		t.Fatal("query did not end")
+	}
+}
+
+func TestConcurrentQueries(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	cl, err := NewClient(&Cfg{
+		Host:             "127.0.0.1",
+		Port:             conn.LocalAddr().(*net.UDPAddr).Port,
+		PacketBufferSize: 1460,
+	})
+	require.NoError(t, err)
+	pages := make(chan *Page, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			page, err := cl.QueryPage(context.Background(), &cmd.QueryParams{}, []byte("secret"))
+			if err != nil {
+				t.Error(err)
+			}
+			pages <- page
+		}()
+	}
+
+	buf := make([]byte, 1460)
+	var raddr net.Addr
+	var ids []uint64
+	for len(ids) < 2 {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		var n int
+		n, raddr, err = conn.ReadFrom(buf)
+		require.NoError(t, err)
+		p := &pkg.Pkg{}
+		require.NoError(t, pkg.Unpack(buf[:n], p))
+		c := &cmd.Cmd{}
+		require.NoError(t, proto.Unmarshal(p.Payload, c))
+		if c.Name == cmd.Name_QUERY {
+			ids = append(ids, c.Id)
+		}
+	}
+	require.NotEqual(t, ids[0], ids[1])
+	send := func(r *cmd.Reply) {
+		raw, err := proto.Marshal(r)
+		require.NoError(t, err)
+		packed, err := pkg.Pack(cmd.Compression_NONE, raw)
+		require.NoError(t, err)
+		_, err = conn.WriteTo(packed, raddr)
+		require.NoError(t, err)
+	}
+	// interleave the replies, each query ending after its own
+	for seq := uint32(0); seq < 3; seq++ {
+		for _, id := range ids {
+			send(&cmd.Reply{Id: id, Seq: seq, Msgs: []*cmd.Msg{{Txt: fmt.Sprintf("%d-%d", id, seq)}}})
+		}
+	}
+	for _, id := range ids {
+		send(&cmd.Reply{Id: id, Seq: 3, Total: proto.Uint32(3), Msgs: []*cmd.Msg{{Key: "//logd", Txt: "+END", Cursor: []byte{byte(id)}}}})
+	}
+	for i := 0; i < 2; i++ {
+		page := <-pages
+		require.Len(t, page.Msgs, 3)
+		id := uint64(page.Cursor[0])
+		for seq, m := range page.Msgs {
+			require.Equal(t, fmt.Sprintf("%d-%d", id, seq), m.Txt)
+		}
+	}
+}
+
+func TestAggregateAsksAgain(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	cl, err := NewClient(&Cfg{
+		Host:             "127.0.0.1",
+		Port:             conn.LocalAddr().(*net.UDPAddr).Port,
+		PacketBufferSize: 1460,
+	})
+	require.NoError(t, err)
+	type result struct {
+		res *cmd.AggregateResult
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := cl.Aggregate(context.Background(), &cmd.QueryParams{}, &cmd.Aggregation{ByKey: true}, []byte("secret"))
+		done <- result{res, err}
+	}()
+
+	want := &cmd.AggregateResult{Matched: 2, Counts: []*cmd.Count{{Key: "/a", N: 1}, {Key: "/b", N: 1}}}
+	raw, err := proto.Marshal(&cmd.Reply{Aggregate: want})
+	require.NoError(t, err)
+	packed, err := pkg.Pack(cmd.Compression_NONE, raw)
+	require.NoError(t, err)
+	buf := make([]byte, 1460)
+	for attempt := uint64(1); attempt <= 2; attempt++ {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		n, raddr, err := conn.ReadFrom(buf)
+		require.NoError(t, err)
+		p := &pkg.Pkg{}
+		require.NoError(t, pkg.Unpack(buf[:n], p))
+		c := &cmd.Cmd{}
+		require.NoError(t, proto.Unmarshal(p.Payload, c))
+		require.Equal(t, cmd.Name_AGGREGATE, c.Name)
+		require.True(t, c.Aggregation.ByKey)
+		// the second fragment of the first attempt is lost
+		half := len(packed) / 2
+		for i, data := range [][]byte{packed[:half], packed[half:]} {
+			if attempt == 1 && i == 1 {
+				continue
+			}
+			raw, err := proto.Marshal(&cmd.Reply{Id: c.Id, Fragment: &cmd.Fragment{
+				Id:    attempt,
+				Index: uint32(i),
+				Total: 2,
+				Data:  data,
+			}})
+			require.NoError(t, err)
+			fragment, err := pkg.Pack(cmd.Compression_NONE, raw)
+			require.NoError(t, err)
+			_, err = conn.WriteTo(fragment, raddr)
+			require.NoError(t, err)
+		}
+	}
+	r := <-done
+	require.NoError(t, r.err)
+	require.True(t, proto.Equal(want, r.res))
+}
+
+func TestTailResumesOnKick(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	cl, err := NewClient(&Cfg{
+		Host:             "127.0.0.1",
+		Port:             conn.LocalAddr().(*net.UDPAddr).Port,
+		PacketBufferSize: 1460,
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msgs, err := cl.Tail(ctx, &cmd.QueryParams{KeyPrefix: proto.String("/test")}, []byte("secret"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 1460)
+	// readTail returns the next TAIL cmd, skipping pings
+	readTail := func() (*cmd.Cmd, net.Addr) {
+		for {
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+			n, raddr, err := conn.ReadFrom(buf)
+			require.NoError(t, err)
+			p := &pkg.Pkg{}
+			require.NoError(t, pkg.Unpack(buf[:n], p))
+			c := &cmd.Cmd{}
+			require.NoError(t, proto.Unmarshal(p.Payload, c))
+			if c.Name == cmd.Name_TAIL {
+				return c, raddr
+			}
+		}
+	}
+	send := func(r *cmd.Reply, raddr net.Addr) {
+		raw, err := proto.Marshal(r)
+		require.NoError(t, err)
+		packed, err := pkg.Pack(cmd.Compression_NONE, raw)
+		require.NoError(t, err)
+		_, err = conn.WriteTo(packed, raddr)
+		require.NoError(t, err)
+	}
+	c, raddr := readTail()
+	require.Nil(t, c.QueryParams.Cursor)
+	send(&cmd.Reply{Id: c.Id, Cursor: &cmd.Cursor{Seqs: map[string]uint64{"/a": 10, "/b": 4}}}, raddr)
+	send(&cmd.Reply{Id: c.Id, Msgs: []*cmd.Msg{{Txt: "x"}}, Cursor: &cmd.Cursor{Seqs: map[string]uint64{"/a": 11}}}, raddr)
+	require.Equal(t, "x", (<-msgs).Txt)
+	send(&cmd.Reply{Id: c.Id, Msgs: []*cmd.Msg{{Key: "//logd", Txt: "kick"}}}, raddr)
+
+	resumed, _ := readTail()
+	require.Equal(t, c.Id, resumed.Id)
+	require.Equal(t, "/test", resumed.QueryParams.GetKeyPrefix())
+	cursor := &cmd.Cursor{}
+	require.NoError(t, proto.Unmarshal(resumed.QueryParams.Cursor, cursor))
+	require.Equal(t, map[string]uint64{"/a": 11, "/b": 4}, cursor.Seqs)
+}
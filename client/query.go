@@ -2,66 +2,188 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/intob/logd/cmd"
-	"github.com/intob/logd/udp"
 	"google.golang.org/protobuf/proto"
 )
 
+const (
+	defaultQueryWindow = 32
+	ackEvery           = 8 // replies received in order, between acks
+	ackTimeout         = 250 * time.Millisecond
+	maxAckTimeouts     = 20 // consecutive, before giving up on the query
+	maxMissing         = 64 // seqs reported per ack
+)
+
+// Page is one page of query results, and the cursor of the next page
+type Page struct {
+	Msgs   []*cmd.Msg
+	Cursor []byte
+}
+
+// Query sends the query, and returns the msgs received. Replies are
+// acknowledged, and resent by logd if lost. The channel is closed
+// early if the query can't be completed.
 func (cl *Client) Query(ctx context.Context, q *cmd.QueryParams, secret []byte) (<-chan *cmd.Msg, error) {
+	return cl.query(ctx, q, secret, nil)
+}
+
+// QueryPage reads a page of results. To read the next page, set
+// q.Cursor to the cursor of the page. Pages are not shifted by logs
+// written in the meantime.
+func (cl *Client) QueryPage(ctx context.Context, q *cmd.QueryParams, secret []byte) (*Page, error) {
+	end := make(chan *cmd.Reply, 1)
+	msgs, err := cl.query(ctx, q, secret, end)
+	if err != nil {
+		return nil, err
+	}
+	page := &Page{}
+	for m := range msgs {
+		page.Msgs = append(page.Msgs, m)
+	}
+	select {
+	case e := <-end:
+		if e.Err != "" {
+			return nil, fmt.Errorf("query failed: %s", e.Err)
+		}
+		if len(e.Msgs) == 0 {
+			return page, errors.New("query ended without cursor")
+		}
+		page.Cursor = e.Msgs[0].Cursor
+		return page, nil
+	default:
+		return page, errors.New("query ended without cursor")
+	}
+}
+
+func (cl *Client) query(ctx context.Context, q *cmd.QueryParams, secret []byte, end chan<- *cmd.Reply) (<-chan *cmd.Msg, error) {
+	q = proto.Clone(q).(*cmd.QueryParams)
+	q.Window = proto.Uint32(cl.queryWindow)
+	id, replies := cl.request()
 	signed, err := cl.SignCmd(ctx, &cmd.Cmd{
 		Name:        cmd.Name_QUERY,
+		Id:          id,
 		QueryParams: q,
 	}, secret)
-	if err != nil {
-		return nil, err
+	if err == nil {
+		err = cl.Wait(ctx)
 	}
-	err = cl.Wait(ctx)
-	if err != nil {
-		return nil, err
+	if err == nil {
+		err = cl.Write(signed)
 	}
-	err = cl.Write(signed)
 	if err != nil {
+		cl.done(id)
 		return nil, err
 	}
 	out := make(chan *cmd.Msg)
-	go cl.readQueryMsgs(out)
+	go func() {
+		defer close(out)
+		defer cl.done(id)
+		err := cl.readReplies(ctx, secret, id, replies, out, end)
+		if err != nil {
+			fmt.Println("query failed:", err)
+		}
+	}()
 	return out, nil
 }
 
-func (c *Client) readQueryMsgs(out chan<- *cmd.Msg) {
-	defer close(out)
-	buf := make([]byte, c.packetBufferSize)
+// received orders the replies to a query, to find those missing
+type received struct {
+	id      uint64 // of the query
+	next    uint32 // seq of the first reply not yet delivered
+	highest uint32
+	replies map[uint32][]*cmd.Msg // after next
+	total   *uint32               // set once the end is received
+	end     *cmd.Reply
+}
+
+// readReplies delivers the msgs of the replies in order, acknowledging
+// them, until the end is delivered. The query is complete only then, as
+// the end is numbered after all other replies. If the end carries an
+// error, as the query failed, the error is returned.
+func (cl *Client) readReplies(ctx context.Context, secret []byte, id uint64, replies <-chan *cmd.Reply, out chan<- *cmd.Msg, end chan<- *cmd.Reply) error {
+	r := &received{id: id, replies: make(map[uint32][]*cmd.Msg)}
+	timer := time.NewTimer(ackTimeout)
+	defer timer.Stop()
+	timeouts, inOrder := 0, 0
 	for {
-		m, err := c.readQueryMsg(buf)
-		if err != nil {
-			fmt.Println("failed to read msg:", err)
-			return
+		var reply *cmd.Reply
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			timeouts++
+			if timeouts > maxAckTimeouts {
+				return fmt.Errorf("incomplete, received %d replies", r.next)
+			}
+			cl.ack(ctx, secret, r)
+			timer.Reset(ackTimeout)
+			continue
+		case reply = <-replies:
 		}
-		if m.Key == udp.ReplyKey && m.Txt == udp.EndMsg {
-			return
+		timeouts = 0
+		timer.Reset(ackTimeout)
+		_, dup := r.replies[reply.Seq]
+		if reply.Seq < r.next || dup {
+			cl.ack(ctx, secret, r) // our ack may have been lost
+			continue
+		}
+		r.replies[reply.Seq] = reply.Msgs
+		r.highest = max(r.highest, reply.Seq)
+		if reply.Total != nil {
+			r.total = reply.Total
+			r.end = reply
+		}
+		gap := reply.Seq != r.next
+		for msgs, ok := r.replies[r.next]; ok; msgs, ok = r.replies[r.next] {
+			delete(r.replies, r.next)
+			if r.total != nil && r.next == *r.total {
+				r.next++
+				cl.ack(ctx, secret, r)
+				if end != nil {
+					end <- r.end
+				}
+				if r.end.Err != "" {
+					return errors.New(r.end.Err)
+				}
+				return nil
+			}
+			r.next++
+			inOrder++
+			for _, m := range msgs {
+				select {
+				case out <- m:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		if gap || inOrder >= ackEvery {
+			inOrder = 0
+			cl.ack(ctx, secret, r)
 		}
-		out <- m
 	}
 }
 
-func (c *Client) readQueryMsg(buf []byte) (*cmd.Msg, error) {
-	buf = buf[:c.packetBufferSize] // re-slice to capacity
-	deadline := time.Now().Add(500 * time.Millisecond)
-	if err := c.conn.SetReadDeadline(deadline); err != nil {
-		return nil, err
+// ack acknowledges the replies received in order, and reports
+// those missing between them and the highest received
+func (cl *Client) ack(ctx context.Context, secret []byte, r *received) {
+	ack := &cmd.Ack{Next: r.next}
+	for seq := r.next; seq < r.highest && len(ack.Missing) < maxMissing; seq++ {
+		if _, ok := r.replies[seq]; !ok {
+			ack.Missing = append(ack.Missing, seq)
+		}
 	}
-	n, err := c.conn.Read(buf)
+	signed, err := cl.SignCmd(ctx, &cmd.Cmd{Name: cmd.Name_ACK, Id: r.id, Ack: ack}, secret)
 	if err != nil {
-		return nil, err
+		fmt.Println("failed to sign ack:", err)
+		return
 	}
-	c.conn.SetReadDeadline(time.Time{})
-	m := &cmd.Msg{}
-	err = proto.Unmarshal(buf[:n], m)
+	err = cl.Write(signed)
 	if err != nil {
-		return nil, err
+		fmt.Println("failed to ack:", err)
 	}
-	return m, nil
 }
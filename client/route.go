@@ -0,0 +1,80 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/pkg"
+	"google.golang.org/protobuf/proto"
+)
+
+// requestBacklog is the number of replies buffered per request,
+// after which they are dropped, rather than blocking others
+const requestBacklog = 256
+
+// request registers a new request, returning its id, and
+// the channel of its replies. It must be ended by done.
+func (cl *Client) request() (uint64, <-chan *cmd.Reply) {
+	cl.routeOnce.Do(func() { go cl.route() })
+	id := cl.nextId.Add(1)
+	replies := make(chan *cmd.Reply, requestBacklog)
+	cl.mu.Lock()
+	cl.requests[id] = replies
+	cl.mu.Unlock()
+	return id, replies
+}
+
+// done stops routing replies to the request
+func (cl *Client) done(id uint64) {
+	cl.mu.Lock()
+	delete(cl.requests, id)
+	cl.mu.Unlock()
+}
+
+// route reads replies from the conn, and passes each to its request
+func (cl *Client) route() {
+	buf := make([]byte, cl.packetBufferSize)
+	for {
+		buf = buf[:cl.packetBufferSize] // re-slice to capacity
+		n, err := cl.conn.Read(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			fmt.Println("err reading from conn:", err)
+			continue
+		}
+		reply, err := unpackReply(buf[:n])
+		if err != nil {
+			fmt.Println("unpack reply err:", err)
+			continue
+		}
+		cl.mu.Lock()
+		replies, ok := cl.requests[reply.Id]
+		cl.mu.Unlock()
+		if !ok {
+			continue // request ended
+		}
+		select {
+		case replies <- reply:
+		default:
+			fmt.Printf("dropped reply to request %d, reader is behind\n", reply.Id)
+		}
+	}
+}
+
+// unpackReply returns a reply packet, flagged as such
+func unpackReply(data []byte) (*cmd.Reply, error) {
+	data, err := pkg.Unpacked(data)
+	if err != nil {
+		return nil, err
+	}
+	r := &cmd.Reply{}
+	err = proto.Unmarshal(data, r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
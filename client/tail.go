@@ -10,34 +10,52 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
+// Tail sends the tail cmd, and returns the msgs received,
+// until ctx is done. It may share the client with queries.
+// If q sets a cursor or tStart, the msgs since are replayed first.
+// When logd kicks the tail, as after a network blip, it is resumed
+// from the last position received, without gaps or duplicates.
 func (cl *Client) Tail(ctx context.Context, q *cmd.QueryParams, secret []byte) (<-chan *cmd.Msg, error) {
-	signed, err := cl.SignCmd(ctx, &cmd.Cmd{
-		Name:        cmd.Name_TAIL,
-		QueryParams: q,
-	}, secret)
-	if err != nil {
-		return nil, err
-	}
-	err = cl.Wait(ctx)
-	if err != nil {
-		return nil, err
-	}
-	err = cl.Write(signed)
+	id, replies := cl.request()
+	err := cl.sendTail(ctx, id, q, secret)
 	if err != nil {
+		cl.done(id)
 		return nil, err
 	}
 	fmt.Printf("\rsent tail cmd\033[0K")
 	out := make(chan *cmd.Msg)
-	go cl.readTailMsgs(out)
-	go cl.ping(ctx, secret)
+	go cl.readTailMsgs(ctx, id, q, secret, replies, out)
+	go cl.ping(ctx, id, secret)
 	return out, nil
 }
 
-func (cl *Client) ping(ctx context.Context, secret []byte) {
+func (cl *Client) sendTail(ctx context.Context, id uint64, q *cmd.QueryParams, secret []byte) error {
+	signed, err := cl.SignCmd(ctx, &cmd.Cmd{
+		Name:        cmd.Name_TAIL,
+		Id:          id,
+		QueryParams: q,
+	}, secret)
+	if err == nil {
+		err = cl.Wait(ctx)
+	}
+	if err == nil {
+		err = cl.Write(signed)
+	}
+	return err
+}
+
+// ping keeps the tail of id alive, and tells logd which tail
+// it is, so that logd can kick it if unknown
+func (cl *Client) ping(ctx context.Context, id uint64, secret []byte) {
 	for {
-		time.Sleep(udp.PingPeriod)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(udp.PingPeriod):
+		}
 		signed, err := cl.SignCmd(ctx, &cmd.Cmd{
 			Name: cmd.Name_PING,
+			Id:   id,
 		}, secret)
 		if err != nil {
 			panic(err)
@@ -50,24 +68,58 @@ func (cl *Client) ping(ctx context.Context, secret []byte) {
 	}
 }
 
-func (c *Client) readTailMsgs(out chan<- *cmd.Msg) {
-	buf := make([]byte, c.packetBufferSize)
+// readTailMsgs delivers the msgs of the tail, keeping the position
+// after them in each ring, from which the tail resumes if kicked
+func (cl *Client) readTailMsgs(ctx context.Context, id uint64, q *cmd.QueryParams, secret []byte, replies <-chan *cmd.Reply, out chan<- *cmd.Msg) {
+	defer close(out)
+	defer cl.done(id)
+	cursor := make(map[string]uint64)
 	for {
-		buf = buf[:c.packetBufferSize] // re-slice to capacity
-		n, err := c.conn.Read(buf)
-		if err != nil {
-			fmt.Printf("\rerror reading from conn: %s\n", err)
+		var reply *cmd.Reply
+		select {
+		case <-ctx.Done():
+			return
+		case reply = <-replies:
 		}
-		m := &cmd.Msg{}
-		err = proto.Unmarshal(buf[:n], m)
-		if err != nil {
-			fmt.Println("unpack msg err:", err)
-			continue
+		for ring, next := range reply.GetCursor().GetSeqs() {
+			cursor[ring] = max(cursor[ring], next)
 		}
-		if m.Key == udp.ReplyKey {
-			fmt.Print(m.Txt)
-			continue
+		if reply.Dropped > 0 {
+			fmt.Printf("\rlogd dropped %d msgs, as the tail fell behind\n", reply.Dropped)
 		}
-		out <- m
+		for _, m := range reply.Msgs {
+			if m.Key == udp.ReplyKey {
+				if m.Txt == udp.KickMsg {
+					cl.resumeTail(ctx, id, q, cursor, secret)
+					continue
+				}
+				fmt.Print(m.Txt)
+				continue
+			}
+			select {
+			case out <- m:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// resumeTail sends the tail cmd again, from the cursor
+// if any position was received, else as first sent
+func (cl *Client) resumeTail(ctx context.Context, id uint64, q *cmd.QueryParams, cursor map[string]uint64, secret []byte) {
+	fmt.Printf("\rresuming tail\033[0K")
+	if len(cursor) > 0 {
+		if q == nil {
+			q = &cmd.QueryParams{}
+		} else {
+			q = proto.Clone(q).(*cmd.QueryParams)
+		}
+		q.TStart = nil
+		q.Cursor, _ = proto.Marshal(&cmd.Cursor{Seqs: cursor})
+	}
+	err := cl.sendTail(ctx, id, q, secret)
+	if err != nil {
+		fmt.Println("failed to resume tail:", err)
 	}
 }
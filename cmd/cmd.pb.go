@@ -24,10 +24,12 @@ const (
 type Name int32
 
 const (
-	Name_WRITE Name = 0
-	Name_TAIL  Name = 1
-	Name_PING  Name = 2
-	Name_QUERY Name = 3
+	Name_WRITE     Name = 0
+	Name_TAIL      Name = 1
+	Name_PING      Name = 2
+	Name_QUERY     Name = 3
+	Name_ACK       Name = 4
+	Name_AGGREGATE Name = 5
 )
 
 // Enum value maps for Name.
@@ -37,12 +39,16 @@ var (
 		1: "TAIL",
 		2: "PING",
 		3: "QUERY",
+		4: "ACK",
+		5: "AGGREGATE",
 	}
 	Name_value = map[string]int32{
-		"WRITE": 0,
-		"TAIL":  1,
-		"PING":  2,
-		"QUERY": 3,
+		"WRITE":     0,
+		"TAIL":      1,
+		"PING":      2,
+		"QUERY":     3,
+		"ACK":       4,
+		"AGGREGATE": 5,
 	}
 )
 
@@ -73,6 +79,103 @@ func (Name) EnumDescriptor() ([]byte, []int) {
 	return file_cmd_proto_rawDescGZIP(), []int{0}
 }
 
+// Compression of a cmd is flagged in the header of its pkg, and of a
+// reply by the first byte of its payload, 0x80 | Compression
+type Compression int32
+
+const (
+	Compression_NONE   Compression = 0
+	Compression_ZSTD   Compression = 1
+	Compression_SNAPPY Compression = 2
+)
+
+// Enum value maps for Compression.
+var (
+	Compression_name = map[int32]string{
+		0: "NONE",
+		1: "ZSTD",
+		2: "SNAPPY",
+	}
+	Compression_value = map[string]int32{
+		"NONE":   0,
+		"ZSTD":   1,
+		"SNAPPY": 2,
+	}
+)
+
+func (x Compression) Enum() *Compression {
+	p := new(Compression)
+	*p = x
+	return p
+}
+
+func (x Compression) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Compression) Descriptor() protoreflect.EnumDescriptor {
+	return file_cmd_proto_enumTypes[1].Descriptor()
+}
+
+func (Compression) Type() protoreflect.EnumType {
+	return &file_cmd_proto_enumTypes[1]
+}
+
+func (x Compression) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Compression.Descriptor instead.
+func (Compression) EnumDescriptor() ([]byte, []int) {
+	return file_cmd_proto_rawDescGZIP(), []int{1}
+}
+
+type Order int32
+
+const (
+	Order_NEWEST_FIRST Order = 0
+	Order_OLDEST_FIRST Order = 1
+)
+
+// Enum value maps for Order.
+var (
+	Order_name = map[int32]string{
+		0: "NEWEST_FIRST",
+		1: "OLDEST_FIRST",
+	}
+	Order_value = map[string]int32{
+		"NEWEST_FIRST": 0,
+		"OLDEST_FIRST": 1,
+	}
+)
+
+func (x Order) Enum() *Order {
+	p := new(Order)
+	*p = x
+	return p
+}
+
+func (x Order) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Order) Descriptor() protoreflect.EnumDescriptor {
+	return file_cmd_proto_enumTypes[2].Descriptor()
+}
+
+func (Order) Type() protoreflect.EnumType {
+	return &file_cmd_proto_enumTypes[2]
+}
+
+func (x Order) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Order.Descriptor instead.
+func (Order) EnumDescriptor() ([]byte, []int) {
+	return file_cmd_proto_rawDescGZIP(), []int{2}
+}
+
 type Lvl int32
 
 const (
@@ -118,11 +221,11 @@ func (x Lvl) String() string {
 }
 
 func (Lvl) Descriptor() protoreflect.EnumDescriptor {
-	return file_cmd_proto_enumTypes[1].Descriptor()
+	return file_cmd_proto_enumTypes[3].Descriptor()
 }
 
 func (Lvl) Type() protoreflect.EnumType {
-	return &file_cmd_proto_enumTypes[1]
+	return &file_cmd_proto_enumTypes[3]
 }
 
 func (x Lvl) Number() protoreflect.EnumNumber {
@@ -131,9 +234,11 @@ func (x Lvl) Number() protoreflect.EnumNumber {
 
 // Deprecated: Use Lvl.Descriptor instead.
 func (Lvl) EnumDescriptor() ([]byte, []int) {
-	return file_cmd_proto_rawDescGZIP(), []int{1}
+	return file_cmd_proto_rawDescGZIP(), []int{3}
 }
 
+// Cmd fields are numbered below 16, so that the first byte of an
+// uncompressed cmd is not taken for the flag of a compressed one
 type Cmd struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -142,6 +247,11 @@ type Cmd struct {
 	Name        Name         `protobuf:"varint,1,opt,name=name,proto3,enum=Name" json:"name,omitempty"`
 	Msg         *Msg         `protobuf:"bytes,2,opt,name=msg,proto3,oneof" json:"msg,omitempty"`
 	QueryParams *QueryParams `protobuf:"bytes,3,opt,name=queryParams,proto3,oneof" json:"queryParams,omitempty"`
+	Msgs        []*Msg       `protobuf:"bytes,4,rep,name=msgs,proto3" json:"msgs,omitempty"`                     // batched WRITE, in addition to msg
+	Fragment    *Fragment    `protobuf:"bytes,5,opt,name=fragment,proto3,oneof" json:"fragment,omitempty"`       // of a WRITE too large for one packet
+	Ack         *Ack         `protobuf:"bytes,6,opt,name=ack,proto3,oneof" json:"ack,omitempty"`                 // of reliable query replies
+	Id          uint64       `protobuf:"varint,7,opt,name=id,proto3" json:"id,omitempty"`                        // of a QUERY, TAIL or AGGREGATE, echoed in its replies, or of the query ACKed or tail PINGed
+	Aggregation *Aggregation `protobuf:"bytes,8,opt,name=aggregation,proto3,oneof" json:"aggregation,omitempty"` // of the msgs matching queryParams
 }
 
 func (x *Cmd) Reset() {
@@ -197,19 +307,56 @@ func (x *Cmd) GetQueryParams() *QueryParams {
 	return nil
 }
 
-type Msg struct {
+func (x *Cmd) GetMsgs() []*Msg {
+	if x != nil {
+		return x.Msgs
+	}
+	return nil
+}
+
+func (x *Cmd) GetFragment() *Fragment {
+	if x != nil {
+		return x.Fragment
+	}
+	return nil
+}
+
+func (x *Cmd) GetAck() *Ack {
+	if x != nil {
+		return x.Ack
+	}
+	return nil
+}
+
+func (x *Cmd) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Cmd) GetAggregation() *Aggregation {
+	if x != nil {
+		return x.Aggregation
+	}
+	return nil
+}
+
+// Aggregation groups the msgs matching a query, counting each group
+type Aggregation struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	T   *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=t,proto3" json:"t,omitempty"`
-	Lvl Lvl                    `protobuf:"varint,6,opt,name=lvl,proto3,enum=Lvl" json:"lvl,omitempty"`
-	Txt string                 `protobuf:"bytes,7,opt,name=txt,proto3" json:"txt,omitempty"`
-	Key string                 `protobuf:"bytes,12,opt,name=key,proto3" json:"key,omitempty"`
+	ByKey    bool   `protobuf:"varint,1,opt,name=byKey,proto3" json:"byKey,omitempty"`
+	KeyDepth uint32 `protobuf:"varint,2,opt,name=keyDepth,proto3" json:"keyDepth,omitempty"` // segments of key grouped by, all if 0
+	ByLvl    bool   `protobuf:"varint,3,opt,name=byLvl,proto3" json:"byLvl,omitempty"`
+	Bucket   uint32 `protobuf:"varint,4,opt,name=bucket,proto3" json:"bucket,omitempty"`   // seconds, msgs are grouped by time if set
+	TopKeys  uint32 `protobuf:"varint,5,opt,name=topKeys,proto3" json:"topKeys,omitempty"` // number of keys counted most, by keyDepth
 }
 
-func (x *Msg) Reset() {
-	*x = Msg{}
+func (x *Aggregation) Reset() {
+	*x = Aggregation{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_cmd_proto_msgTypes[1]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -217,13 +364,13 @@ func (x *Msg) Reset() {
 	}
 }
 
-func (x *Msg) String() string {
+func (x *Aggregation) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Msg) ProtoMessage() {}
+func (*Aggregation) ProtoMessage() {}
 
-func (x *Msg) ProtoReflect() protoreflect.Message {
+func (x *Aggregation) ProtoReflect() protoreflect.Message {
 	mi := &file_cmd_proto_msgTypes[1]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -235,54 +382,60 @@ func (x *Msg) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Msg.ProtoReflect.Descriptor instead.
-func (*Msg) Descriptor() ([]byte, []int) {
+// Deprecated: Use Aggregation.ProtoReflect.Descriptor instead.
+func (*Aggregation) Descriptor() ([]byte, []int) {
 	return file_cmd_proto_rawDescGZIP(), []int{1}
 }
 
-func (x *Msg) GetT() *timestamppb.Timestamp {
+func (x *Aggregation) GetByKey() bool {
 	if x != nil {
-		return x.T
+		return x.ByKey
 	}
-	return nil
+	return false
 }
 
-func (x *Msg) GetLvl() Lvl {
+func (x *Aggregation) GetKeyDepth() uint32 {
 	if x != nil {
-		return x.Lvl
+		return x.KeyDepth
 	}
-	return Lvl_LVL_UNKNOWN
+	return 0
 }
 
-func (x *Msg) GetTxt() string {
+func (x *Aggregation) GetByLvl() bool {
 	if x != nil {
-		return x.Txt
+		return x.ByLvl
 	}
-	return ""
+	return false
 }
 
-func (x *Msg) GetKey() string {
+func (x *Aggregation) GetBucket() uint32 {
 	if x != nil {
-		return x.Key
+		return x.Bucket
 	}
-	return ""
+	return 0
 }
 
-type QueryParams struct {
+func (x *Aggregation) GetTopKeys() uint32 {
+	if x != nil {
+		return x.TopKeys
+	}
+	return 0
+}
+
+// AggregateResult is the reply to an AGGREGATE cmd
+type AggregateResult struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Offset    *uint32                `protobuf:"varint,1,opt,name=offset,proto3,oneof" json:"offset,omitempty"`
-	Limit     *uint32                `protobuf:"varint,2,opt,name=limit,proto3,oneof" json:"limit,omitempty"`
-	TStart    *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=tStart,proto3,oneof" json:"tStart,omitempty"`
-	TEnd      *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=tEnd,proto3,oneof" json:"tEnd,omitempty"`
-	Lvl       *Lvl                   `protobuf:"varint,8,opt,name=lvl,proto3,enum=Lvl,oneof" json:"lvl,omitempty"`
-	KeyPrefix *string                `protobuf:"bytes,13,opt,name=keyPrefix,proto3,oneof" json:"keyPrefix,omitempty"`
+	Counts    []*Count `protobuf:"bytes,1,rep,name=counts,proto3" json:"counts,omitempty"`
+	TopKeys   []*Count `protobuf:"bytes,2,rep,name=topKeys,proto3" json:"topKeys,omitempty"` // most first
+	Matched   uint64   `protobuf:"varint,3,opt,name=matched,proto3" json:"matched,omitempty"`
+	Truncated bool     `protobuf:"varint,4,opt,name=truncated,proto3" json:"truncated,omitempty"` // at the aggregate limit of logd
 }
 
-func (x *QueryParams) Reset() {
-	*x = QueryParams{}
+func (x *AggregateResult) Reset() {
+	*x = AggregateResult{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_cmd_proto_msgTypes[2]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -290,13 +443,13 @@ func (x *QueryParams) Reset() {
 	}
 }
 
-func (x *QueryParams) String() string {
+func (x *AggregateResult) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*QueryParams) ProtoMessage() {}
+func (*AggregateResult) ProtoMessage() {}
 
-func (x *QueryParams) ProtoReflect() protoreflect.Message {
+func (x *AggregateResult) ProtoReflect() protoreflect.Message {
 	mi := &file_cmd_proto_msgTypes[2]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -308,175 +461,1181 @@ func (x *QueryParams) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use QueryParams.ProtoReflect.Descriptor instead.
-func (*QueryParams) Descriptor() ([]byte, []int) {
+// Deprecated: Use AggregateResult.ProtoReflect.Descriptor instead.
+func (*AggregateResult) Descriptor() ([]byte, []int) {
 	return file_cmd_proto_rawDescGZIP(), []int{2}
 }
 
-func (x *QueryParams) GetOffset() uint32 {
-	if x != nil && x.Offset != nil {
-		return *x.Offset
+func (x *AggregateResult) GetCounts() []*Count {
+	if x != nil {
+		return x.Counts
 	}
-	return 0
+	return nil
 }
 
-func (x *QueryParams) GetLimit() uint32 {
-	if x != nil && x.Limit != nil {
-		return *x.Limit
+func (x *AggregateResult) GetTopKeys() []*Count {
+	if x != nil {
+		return x.TopKeys
+	}
+	return nil
+}
+
+func (x *AggregateResult) GetMatched() uint64 {
+	if x != nil {
+		return x.Matched
 	}
 	return 0
 }
 
-func (x *QueryParams) GetTStart() *timestamppb.Timestamp {
+func (x *AggregateResult) GetTruncated() bool {
 	if x != nil {
-		return x.TStart
+		return x.Truncated
 	}
-	return nil
+	return false
 }
 
-func (x *QueryParams) GetTEnd() *timestamppb.Timestamp {
+// Count is the number of msgs in a group, fields not grouped by are zero
+type Count struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Lvl Lvl                    `protobuf:"varint,2,opt,name=lvl,proto3,enum=Lvl" json:"lvl,omitempty"`
+	T   *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=t,proto3" json:"t,omitempty"` // start of the bucket
+	N   uint64                 `protobuf:"varint,4,opt,name=n,proto3" json:"n,omitempty"`
+}
+
+func (x *Count) Reset() {
+	*x = Count{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cmd_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Count) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Count) ProtoMessage() {}
+
+func (x *Count) ProtoReflect() protoreflect.Message {
+	mi := &file_cmd_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Count.ProtoReflect.Descriptor instead.
+func (*Count) Descriptor() ([]byte, []int) {
+	return file_cmd_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Count) GetKey() string {
 	if x != nil {
-		return x.TEnd
+		return x.Key
 	}
-	return nil
+	return ""
 }
 
-func (x *QueryParams) GetLvl() Lvl {
-	if x != nil && x.Lvl != nil {
-		return *x.Lvl
+func (x *Count) GetLvl() Lvl {
+	if x != nil {
+		return x.Lvl
 	}
 	return Lvl_LVL_UNKNOWN
 }
 
-func (x *QueryParams) GetKeyPrefix() string {
-	if x != nil && x.KeyPrefix != nil {
-		return *x.KeyPrefix
+func (x *Count) GetT() *timestamppb.Timestamp {
+	if x != nil {
+		return x.T
 	}
-	return ""
+	return nil
 }
 
-var File_cmd_proto protoreflect.FileDescriptor
+func (x *Count) GetN() uint64 {
+	if x != nil {
+		return x.N
+	}
+	return 0
+}
 
-var file_cmd_proto_rawDesc = []byte{
-	0x0a, 0x09, 0x63, 0x6d, 0x64, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1f, 0x67, 0x6f, 0x6f,
-	0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d,
-	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x8a, 0x01, 0x0a,
-	0x03, 0x43, 0x6d, 0x64, 0x12, 0x19, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x0e, 0x32, 0x05, 0x2e, 0x4e, 0x61, 0x6d, 0x65, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12,
-	0x1b, 0x0a, 0x03, 0x6d, 0x73, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x04, 0x2e, 0x4d,
-	0x73, 0x67, 0x48, 0x00, 0x52, 0x03, 0x6d, 0x73, 0x67, 0x88, 0x01, 0x01, 0x12, 0x33, 0x0a, 0x0b,
-	0x71, 0x75, 0x65, 0x72, 0x79, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28,
-	0x0b, 0x32, 0x0c, 0x2e, 0x51, 0x75, 0x65, 0x72, 0x79, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x48,
-	0x01, 0x52, 0x0b, 0x71, 0x75, 0x65, 0x72, 0x79, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x88, 0x01,
-	0x01, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x6d, 0x73, 0x67, 0x42, 0x0e, 0x0a, 0x0c, 0x5f, 0x71, 0x75,
-	0x65, 0x72, 0x79, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x22, 0x6b, 0x0a, 0x03, 0x4d, 0x73, 0x67,
-	0x12, 0x28, 0x0a, 0x01, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f,
-	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69,
-	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x01, 0x74, 0x12, 0x16, 0x0a, 0x03, 0x6c, 0x76,
-	0x6c, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x04, 0x2e, 0x4c, 0x76, 0x6c, 0x52, 0x03, 0x6c,
-	0x76, 0x6c, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x78, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x03, 0x74, 0x78, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x0c, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x22, 0xb2, 0x02, 0x0a, 0x0b, 0x51, 0x75, 0x65, 0x72, 0x79,
-	0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x12, 0x1b, 0x0a, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x00, 0x52, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74,
-	0x88, 0x01, 0x01, 0x12, 0x19, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x18, 0x02, 0x20, 0x01,
-	0x28, 0x0d, 0x48, 0x01, 0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x88, 0x01, 0x01, 0x12, 0x37,
-	0x0a, 0x06, 0x74, 0x53, 0x74, 0x61, 0x72, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a,
-	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
-	0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x48, 0x02, 0x52, 0x06, 0x74, 0x53,
-	0x74, 0x61, 0x72, 0x74, 0x88, 0x01, 0x01, 0x12, 0x33, 0x0a, 0x04, 0x74, 0x45, 0x6e, 0x64, 0x18,
-	0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70,
-	0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
-	0x70, 0x48, 0x03, 0x52, 0x04, 0x74, 0x45, 0x6e, 0x64, 0x88, 0x01, 0x01, 0x12, 0x1b, 0x0a, 0x03,
-	0x6c, 0x76, 0x6c, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x04, 0x2e, 0x4c, 0x76, 0x6c, 0x48,
-	0x04, 0x52, 0x03, 0x6c, 0x76, 0x6c, 0x88, 0x01, 0x01, 0x12, 0x21, 0x0a, 0x09, 0x6b, 0x65, 0x79,
-	0x50, 0x72, 0x65, 0x66, 0x69, 0x78, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x09, 0x48, 0x05, 0x52, 0x09,
-	0x6b, 0x65, 0x79, 0x50, 0x72, 0x65, 0x66, 0x69, 0x78, 0x88, 0x01, 0x01, 0x42, 0x09, 0x0a, 0x07,
-	0x5f, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x6c, 0x69, 0x6d, 0x69,
-	0x74, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x74, 0x53, 0x74, 0x61, 0x72, 0x74, 0x42, 0x07, 0x0a, 0x05,
-	0x5f, 0x74, 0x45, 0x6e, 0x64, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x6c, 0x76, 0x6c, 0x42, 0x0c, 0x0a,
-	0x0a, 0x5f, 0x6b, 0x65, 0x79, 0x50, 0x72, 0x65, 0x66, 0x69, 0x78, 0x2a, 0x30, 0x0a, 0x04, 0x4e,
-	0x61, 0x6d, 0x65, 0x12, 0x09, 0x0a, 0x05, 0x57, 0x52, 0x49, 0x54, 0x45, 0x10, 0x00, 0x12, 0x08,
-	0x0a, 0x04, 0x54, 0x41, 0x49, 0x4c, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x50, 0x49, 0x4e, 0x47,
-	0x10, 0x02, 0x12, 0x09, 0x0a, 0x05, 0x51, 0x55, 0x45, 0x52, 0x59, 0x10, 0x03, 0x2a, 0x56, 0x0a,
-	0x03, 0x4c, 0x76, 0x6c, 0x12, 0x0f, 0x0a, 0x0b, 0x4c, 0x56, 0x4c, 0x5f, 0x55, 0x4e, 0x4b, 0x4e,
-	0x4f, 0x57, 0x4e, 0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x54, 0x52, 0x41, 0x43, 0x45, 0x10, 0x01,
-	0x12, 0x09, 0x0a, 0x05, 0x44, 0x45, 0x42, 0x55, 0x47, 0x10, 0x02, 0x12, 0x08, 0x0a, 0x04, 0x49,
-	0x4e, 0x46, 0x4f, 0x10, 0x03, 0x12, 0x08, 0x0a, 0x04, 0x57, 0x41, 0x52, 0x4e, 0x10, 0x04, 0x12,
-	0x09, 0x0a, 0x05, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x10, 0x05, 0x12, 0x09, 0x0a, 0x05, 0x46, 0x41,
-	0x54, 0x41, 0x4c, 0x10, 0x06, 0x42, 0x07, 0x5a, 0x05, 0x2e, 0x2f, 0x63, 0x6d, 0x64, 0x62, 0x06,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+// Ack acknowledges the replies to a reliable query
+type Ack struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Next    uint32   `protobuf:"varint,1,opt,name=next,proto3" json:"next,omitempty"`              // seq of the first reply not received
+	Missing []uint32 `protobuf:"varint,2,rep,packed,name=missing,proto3" json:"missing,omitempty"` // seqs not received, after next
 }
 
-var (
-	file_cmd_proto_rawDescOnce sync.Once
-	file_cmd_proto_rawDescData = file_cmd_proto_rawDesc
-)
+func (x *Ack) Reset() {
+	*x = Ack{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cmd_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
 
-func file_cmd_proto_rawDescGZIP() []byte {
-	file_cmd_proto_rawDescOnce.Do(func() {
-		file_cmd_proto_rawDescData = protoimpl.X.CompressGZIP(file_cmd_proto_rawDescData)
-	})
-	return file_cmd_proto_rawDescData
+func (x *Ack) String() string {
+	return protoimpl.X.MessageStringOf(x)
 }
 
-var file_cmd_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_cmd_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
-var file_cmd_proto_goTypes = []interface{}{
-	(Name)(0),                     // 0: Name
-	(Lvl)(0),                      // 1: Lvl
-	(*Cmd)(nil),                   // 2: Cmd
-	(*Msg)(nil),                   // 3: Msg
-	(*QueryParams)(nil),           // 4: QueryParams
-	(*timestamppb.Timestamp)(nil), // 5: google.protobuf.Timestamp
+func (*Ack) ProtoMessage() {}
+
+func (x *Ack) ProtoReflect() protoreflect.Message {
+	mi := &file_cmd_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
 }
-var file_cmd_proto_depIdxs = []int32{
-	0, // 0: Cmd.name:type_name -> Name
-	3, // 1: Cmd.msg:type_name -> Msg
-	4, // 2: Cmd.queryParams:type_name -> QueryParams
-	5, // 3: Msg.t:type_name -> google.protobuf.Timestamp
-	1, // 4: Msg.lvl:type_name -> Lvl
-	5, // 5: QueryParams.tStart:type_name -> google.protobuf.Timestamp
-	5, // 6: QueryParams.tEnd:type_name -> google.protobuf.Timestamp
-	1, // 7: QueryParams.lvl:type_name -> Lvl
-	8, // [8:8] is the sub-list for method output_type
-	8, // [8:8] is the sub-list for method input_type
-	8, // [8:8] is the sub-list for extension type_name
-	8, // [8:8] is the sub-list for extension extendee
-	0, // [0:8] is the sub-list for field type_name
+
+// Deprecated: Use Ack.ProtoReflect.Descriptor instead.
+func (*Ack) Descriptor() ([]byte, []int) {
+	return file_cmd_proto_rawDescGZIP(), []int{4}
 }
 
-func init() { file_cmd_proto_init() }
-func file_cmd_proto_init() {
-	if File_cmd_proto != nil {
-		return
+func (x *Ack) GetNext() uint32 {
+	if x != nil {
+		return x.Next
 	}
-	if !protoimpl.UnsafeEnabled {
-		file_cmd_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Cmd); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
-			}
-		}
-		file_cmd_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Msg); i {
-			case 0:
-				return &v.state
-			case 1:
-				return &v.sizeCache
-			case 2:
-				return &v.unknownFields
-			default:
-				return nil
+	return 0
+}
+
+func (x *Ack) GetMissing() []uint32 {
+	if x != nil {
+		return x.Missing
+	}
+	return nil
+}
+
+// Fragment is a part of the payload of a cmd, reassembled by id
+type Fragment struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id          uint64      `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Index       uint32      `protobuf:"varint,2,opt,name=index,proto3" json:"index,omitempty"`
+	Total       uint32      `protobuf:"varint,3,opt,name=total,proto3" json:"total,omitempty"`
+	Data        []byte      `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	Compression Compression `protobuf:"varint,5,opt,name=compression,proto3,enum=Compression" json:"compression,omitempty"` // of the payload reassembled
+}
+
+func (x *Fragment) Reset() {
+	*x = Fragment{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cmd_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Fragment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Fragment) ProtoMessage() {}
+
+func (x *Fragment) ProtoReflect() protoreflect.Message {
+	mi := &file_cmd_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Fragment.ProtoReflect.Descriptor instead.
+func (*Fragment) Descriptor() ([]byte, []int) {
+	return file_cmd_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Fragment) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Fragment) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *Fragment) GetTotal() uint32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *Fragment) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Fragment) GetCompression() Compression {
+	if x != nil {
+		return x.Compression
+	}
+	return Compression_NONE
+}
+
+type Msg struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	T      *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=t,proto3" json:"t,omitempty"`
+	Attrs  map[string]*Attr       `protobuf:"bytes,2,rep,name=attrs,proto3" json:"attrs,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Lvl    Lvl                    `protobuf:"varint,6,opt,name=lvl,proto3,enum=Lvl" json:"lvl,omitempty"`
+	Txt    string                 `protobuf:"bytes,7,opt,name=txt,proto3" json:"txt,omitempty"`
+	Key    string                 `protobuf:"bytes,12,opt,name=key,proto3" json:"key,omitempty"`
+	Seq    uint64                 `protobuf:"varint,13,opt,name=seq,proto3" json:"seq,omitempty"`      // set by logd in query results, unique per ring
+	Cursor []byte                 `protobuf:"bytes,14,opt,name=cursor,proto3" json:"cursor,omitempty"` // set by logd in the end of query reply
+}
+
+func (x *Msg) Reset() {
+	*x = Msg{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cmd_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Msg) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Msg) ProtoMessage() {}
+
+func (x *Msg) ProtoReflect() protoreflect.Message {
+	mi := &file_cmd_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Msg.ProtoReflect.Descriptor instead.
+func (*Msg) Descriptor() ([]byte, []int) {
+	return file_cmd_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Msg) GetT() *timestamppb.Timestamp {
+	if x != nil {
+		return x.T
+	}
+	return nil
+}
+
+func (x *Msg) GetAttrs() map[string]*Attr {
+	if x != nil {
+		return x.Attrs
+	}
+	return nil
+}
+
+func (x *Msg) GetLvl() Lvl {
+	if x != nil {
+		return x.Lvl
+	}
+	return Lvl_LVL_UNKNOWN
+}
+
+func (x *Msg) GetTxt() string {
+	if x != nil {
+		return x.Txt
+	}
+	return ""
+}
+
+func (x *Msg) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *Msg) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Msg) GetCursor() []byte {
+	if x != nil {
+		return x.Cursor
+	}
+	return nil
+}
+
+type QueryParams struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Offset        *uint32                `protobuf:"varint,1,opt,name=offset,proto3,oneof" json:"offset,omitempty"`
+	Limit         *uint32                `protobuf:"varint,2,opt,name=limit,proto3,oneof" json:"limit,omitempty"`
+	TStart        *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=tStart,proto3,oneof" json:"tStart,omitempty"` // of a TAIL, replays msgs since
+	TEnd          *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=tEnd,proto3,oneof" json:"tEnd,omitempty"`
+	Txt           *string                `protobuf:"bytes,5,opt,name=txt,proto3,oneof" json:"txt,omitempty"` // substring of msg txt
+	TxtIgnoreCase *bool                  `protobuf:"varint,6,opt,name=txtIgnoreCase,proto3,oneof" json:"txtIgnoreCase,omitempty"`
+	TxtRegex      *string                `protobuf:"bytes,7,opt,name=txtRegex,proto3,oneof" json:"txtRegex,omitempty"` // RE2 syntax
+	Lvl           *Lvl                   `protobuf:"varint,8,opt,name=lvl,proto3,enum=Lvl,oneof" json:"lvl,omitempty"`
+	Attrs         []*AttrFilter          `protobuf:"bytes,9,rep,name=attrs,proto3" json:"attrs,omitempty"`                                      // all must match
+	Compression   *Compression           `protobuf:"varint,10,opt,name=compression,proto3,enum=Compression,oneof" json:"compression,omitempty"` // of replies, which are then batched
+	Window        *uint32                `protobuf:"varint,11,opt,name=window,proto3,oneof" json:"window,omitempty"`                            // replies in flight, asks for reliable query replies
+	KeyPrefix     *string                `protobuf:"bytes,13,opt,name=keyPrefix,proto3,oneof" json:"keyPrefix,omitempty"`
+	Cursor        []byte                 `protobuf:"bytes,14,opt,name=cursor,proto3,oneof" json:"cursor,omitempty"` // from the end of the previous page, or of a tail to resume
+	Order         *Order                 `protobuf:"varint,15,opt,name=order,proto3,enum=Order,oneof" json:"order,omitempty"`
+}
+
+func (x *QueryParams) Reset() {
+	*x = QueryParams{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cmd_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *QueryParams) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryParams) ProtoMessage() {}
+
+func (x *QueryParams) ProtoReflect() protoreflect.Message {
+	mi := &file_cmd_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryParams.ProtoReflect.Descriptor instead.
+func (*QueryParams) Descriptor() ([]byte, []int) {
+	return file_cmd_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *QueryParams) GetOffset() uint32 {
+	if x != nil && x.Offset != nil {
+		return *x.Offset
+	}
+	return 0
+}
+
+func (x *QueryParams) GetLimit() uint32 {
+	if x != nil && x.Limit != nil {
+		return *x.Limit
+	}
+	return 0
+}
+
+func (x *QueryParams) GetTStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.TStart
+	}
+	return nil
+}
+
+func (x *QueryParams) GetTEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.TEnd
+	}
+	return nil
+}
+
+func (x *QueryParams) GetTxt() string {
+	if x != nil && x.Txt != nil {
+		return *x.Txt
+	}
+	return ""
+}
+
+func (x *QueryParams) GetTxtIgnoreCase() bool {
+	if x != nil && x.TxtIgnoreCase != nil {
+		return *x.TxtIgnoreCase
+	}
+	return false
+}
+
+func (x *QueryParams) GetTxtRegex() string {
+	if x != nil && x.TxtRegex != nil {
+		return *x.TxtRegex
+	}
+	return ""
+}
+
+func (x *QueryParams) GetLvl() Lvl {
+	if x != nil && x.Lvl != nil {
+		return *x.Lvl
+	}
+	return Lvl_LVL_UNKNOWN
+}
+
+func (x *QueryParams) GetAttrs() []*AttrFilter {
+	if x != nil {
+		return x.Attrs
+	}
+	return nil
+}
+
+func (x *QueryParams) GetCompression() Compression {
+	if x != nil && x.Compression != nil {
+		return *x.Compression
+	}
+	return Compression_NONE
+}
+
+func (x *QueryParams) GetWindow() uint32 {
+	if x != nil && x.Window != nil {
+		return *x.Window
+	}
+	return 0
+}
+
+func (x *QueryParams) GetKeyPrefix() string {
+	if x != nil && x.KeyPrefix != nil {
+		return *x.KeyPrefix
+	}
+	return ""
+}
+
+func (x *QueryParams) GetCursor() []byte {
+	if x != nil {
+		return x.Cursor
+	}
+	return nil
+}
+
+func (x *QueryParams) GetOrder() Order {
+	if x != nil && x.Order != nil {
+		return *x.Order
+	}
+	return Order_NEWEST_FIRST
+}
+
+// Attr is a typed value of a msg attribute
+type Attr struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to Value:
+	//	*Attr_Str
+	//	*Attr_Int
+	//	*Attr_Float
+	//	*Attr_Bool
+	Value isAttr_Value `protobuf_oneof:"value"`
+}
+
+func (x *Attr) Reset() {
+	*x = Attr{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cmd_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Attr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Attr) ProtoMessage() {}
+
+func (x *Attr) ProtoReflect() protoreflect.Message {
+	mi := &file_cmd_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Attr.ProtoReflect.Descriptor instead.
+func (*Attr) Descriptor() ([]byte, []int) {
+	return file_cmd_proto_rawDescGZIP(), []int{8}
+}
+
+func (m *Attr) GetValue() isAttr_Value {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (x *Attr) GetStr() string {
+	if x, ok := x.GetValue().(*Attr_Str); ok {
+		return x.Str
+	}
+	return ""
+}
+
+func (x *Attr) GetInt() int64 {
+	if x, ok := x.GetValue().(*Attr_Int); ok {
+		return x.Int
+	}
+	return 0
+}
+
+func (x *Attr) GetFloat() float64 {
+	if x, ok := x.GetValue().(*Attr_Float); ok {
+		return x.Float
+	}
+	return 0
+}
+
+func (x *Attr) GetBool() bool {
+	if x, ok := x.GetValue().(*Attr_Bool); ok {
+		return x.Bool
+	}
+	return false
+}
+
+type isAttr_Value interface {
+	isAttr_Value()
+}
+
+type Attr_Str struct {
+	Str string `protobuf:"bytes,1,opt,name=str,proto3,oneof"`
+}
+
+type Attr_Int struct {
+	Int int64 `protobuf:"varint,2,opt,name=int,proto3,oneof"`
+}
+
+type Attr_Float struct {
+	Float float64 `protobuf:"fixed64,3,opt,name=float,proto3,oneof"`
+}
+
+type Attr_Bool struct {
+	Bool bool `protobuf:"varint,4,opt,name=bool,proto3,oneof"`
+}
+
+func (*Attr_Str) isAttr_Value() {}
+
+func (*Attr_Int) isAttr_Value() {}
+
+func (*Attr_Float) isAttr_Value() {}
+
+func (*Attr_Bool) isAttr_Value() {}
+
+// AttrFilter matches msgs having the attribute key, equal to eq,
+// and if numeric, within min and max inclusive
+type AttrFilter struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Key string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Eq  *Attr    `protobuf:"bytes,2,opt,name=eq,proto3,oneof" json:"eq,omitempty"`
+	Min *float64 `protobuf:"fixed64,3,opt,name=min,proto3,oneof" json:"min,omitempty"`
+	Max *float64 `protobuf:"fixed64,4,opt,name=max,proto3,oneof" json:"max,omitempty"`
+}
+
+func (x *AttrFilter) Reset() {
+	*x = AttrFilter{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cmd_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AttrFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AttrFilter) ProtoMessage() {}
+
+func (x *AttrFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_cmd_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AttrFilter.ProtoReflect.Descriptor instead.
+func (*AttrFilter) Descriptor() ([]byte, []int) {
+	return file_cmd_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *AttrFilter) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *AttrFilter) GetEq() *Attr {
+	if x != nil {
+		return x.Eq
+	}
+	return nil
+}
+
+func (x *AttrFilter) GetMin() float64 {
+	if x != nil && x.Min != nil {
+		return *x.Min
+	}
+	return 0
+}
+
+func (x *AttrFilter) GetMax() float64 {
+	if x != nil && x.Max != nil {
+		return *x.Max
+	}
+	return 0
+}
+
+// Reply is a batch of msgs, sent compressed to queries & tails
+// that ask for compression, and to reliable or identified ones
+type Reply struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Msgs      []*Msg           `protobuf:"bytes,1,rep,name=msgs,proto3" json:"msgs,omitempty"`
+	Seq       uint32           `protobuf:"varint,2,opt,name=seq,proto3" json:"seq,omitempty"`           // of reliable query replies
+	Total     *uint32          `protobuf:"varint,3,opt,name=total,proto3,oneof" json:"total,omitempty"` // replies before the end, set in the end
+	Id        uint64           `protobuf:"varint,4,opt,name=id,proto3" json:"id,omitempty"`             // of the cmd replied to
+	Aggregate *AggregateResult `protobuf:"bytes,5,opt,name=aggregate,proto3,oneof" json:"aggregate,omitempty"`
+	Fragment  *Fragment        `protobuf:"bytes,6,opt,name=fragment,proto3,oneof" json:"fragment,omitempty"` // of a reply too large for one packet
+	Cursor    *Cursor          `protobuf:"bytes,7,opt,name=cursor,proto3,oneof" json:"cursor,omitempty"`     // of tail replies, position after the msgs, in their rings
+	Dropped   uint64           `protobuf:"varint,8,opt,name=dropped,proto3" json:"dropped,omitempty"`        // msgs of a tail dropped before these, as it fell behind
+	Err       string           `protobuf:"bytes,9,opt,name=err,proto3" json:"err,omitempty"`                 // why the query or aggregate failed, ending it
+}
+
+func (x *Reply) Reset() {
+	*x = Reply{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cmd_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Reply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Reply) ProtoMessage() {}
+
+func (x *Reply) ProtoReflect() protoreflect.Message {
+	mi := &file_cmd_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Reply.ProtoReflect.Descriptor instead.
+func (*Reply) Descriptor() ([]byte, []int) {
+	return file_cmd_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Reply) GetMsgs() []*Msg {
+	if x != nil {
+		return x.Msgs
+	}
+	return nil
+}
+
+func (x *Reply) GetSeq() uint32 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Reply) GetTotal() uint32 {
+	if x != nil && x.Total != nil {
+		return *x.Total
+	}
+	return 0
+}
+
+func (x *Reply) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Reply) GetAggregate() *AggregateResult {
+	if x != nil {
+		return x.Aggregate
+	}
+	return nil
+}
+
+func (x *Reply) GetFragment() *Fragment {
+	if x != nil {
+		return x.Fragment
+	}
+	return nil
+}
+
+func (x *Reply) GetCursor() *Cursor {
+	if x != nil {
+		return x.Cursor
+	}
+	return nil
+}
+
+func (x *Reply) GetDropped() uint64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
+func (x *Reply) GetErr() string {
+	if x != nil {
+		return x.Err
+	}
+	return ""
+}
+
+// Cursor is a position in each ring, encoded opaquely in query params
+type Cursor struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Seqs map[string]uint64 `protobuf:"bytes,1,rep,name=seqs,proto3" json:"seqs,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+}
+
+func (x *Cursor) Reset() {
+	*x = Cursor{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_cmd_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Cursor) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Cursor) ProtoMessage() {}
+
+func (x *Cursor) ProtoReflect() protoreflect.Message {
+	mi := &file_cmd_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Cursor.ProtoReflect.Descriptor instead.
+func (*Cursor) Descriptor() ([]byte, []int) {
+	return file_cmd_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Cursor) GetSeqs() map[string]uint64 {
+	if x != nil {
+		return x.Seqs
+	}
+	return nil
+}
+
+var File_cmd_proto protoreflect.FileDescriptor
+
+var file_cmd_proto_rawDesc = []byte{
+	0x0a, 0x09, 0x63, 0x6d, 0x64, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1f, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xd7, 0x02, 0x0a,
+	0x03, 0x43, 0x6d, 0x64, 0x12, 0x19, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x0e, 0x32, 0x05, 0x2e, 0x4e, 0x61, 0x6d, 0x65, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12,
+	0x1b, 0x0a, 0x03, 0x6d, 0x73, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x04, 0x2e, 0x4d,
+	0x73, 0x67, 0x48, 0x00, 0x52, 0x03, 0x6d, 0x73, 0x67, 0x88, 0x01, 0x01, 0x12, 0x33, 0x0a, 0x0b,
+	0x71, 0x75, 0x65, 0x72, 0x79, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x0c, 0x2e, 0x51, 0x75, 0x65, 0x72, 0x79, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x48,
+	0x01, 0x52, 0x0b, 0x71, 0x75, 0x65, 0x72, 0x79, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x88, 0x01,
+	0x01, 0x12, 0x18, 0x0a, 0x04, 0x6d, 0x73, 0x67, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x04, 0x2e, 0x4d, 0x73, 0x67, 0x52, 0x04, 0x6d, 0x73, 0x67, 0x73, 0x12, 0x2a, 0x0a, 0x08, 0x66,
+	0x72, 0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x09, 0x2e,
+	0x46, 0x72, 0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x48, 0x02, 0x52, 0x08, 0x66, 0x72, 0x61, 0x67,
+	0x6d, 0x65, 0x6e, 0x74, 0x88, 0x01, 0x01, 0x12, 0x1b, 0x0a, 0x03, 0x61, 0x63, 0x6b, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x04, 0x2e, 0x41, 0x63, 0x6b, 0x48, 0x03, 0x52, 0x03, 0x61, 0x63,
+	0x6b, 0x88, 0x01, 0x01, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x07, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x02, 0x69, 0x64, 0x12, 0x33, 0x0a, 0x0b, 0x61, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x41, 0x67, 0x67, 0x72,
+	0x65, 0x67, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x04, 0x52, 0x0b, 0x61, 0x67, 0x67, 0x72, 0x65,
+	0x67, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x88, 0x01, 0x01, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x6d, 0x73,
+	0x67, 0x42, 0x0e, 0x0a, 0x0c, 0x5f, 0x71, 0x75, 0x65, 0x72, 0x79, 0x50, 0x61, 0x72, 0x61, 0x6d,
+	0x73, 0x42, 0x0b, 0x0a, 0x09, 0x5f, 0x66, 0x72, 0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x42, 0x06,
+	0x0a, 0x04, 0x5f, 0x61, 0x63, 0x6b, 0x42, 0x0e, 0x0a, 0x0c, 0x5f, 0x61, 0x67, 0x67, 0x72, 0x65,
+	0x67, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x87, 0x01, 0x0a, 0x0b, 0x41, 0x67, 0x67, 0x72, 0x65,
+	0x67, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x62, 0x79, 0x4b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x62, 0x79, 0x4b, 0x65, 0x79, 0x12, 0x1a, 0x0a, 0x08,
+	0x6b, 0x65, 0x79, 0x44, 0x65, 0x70, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x08,
+	0x6b, 0x65, 0x79, 0x44, 0x65, 0x70, 0x74, 0x68, 0x12, 0x14, 0x0a, 0x05, 0x62, 0x79, 0x4c, 0x76,
+	0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x05, 0x62, 0x79, 0x4c, 0x76, 0x6c, 0x12, 0x16,
+	0x0a, 0x06, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06,
+	0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x74, 0x6f, 0x70, 0x4b, 0x65, 0x79,
+	0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x74, 0x6f, 0x70, 0x4b, 0x65, 0x79, 0x73,
+	0x22, 0x8b, 0x01, 0x0a, 0x0f, 0x41, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x65, 0x52, 0x65,
+	0x73, 0x75, 0x6c, 0x74, 0x12, 0x1e, 0x0a, 0x06, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x06, 0x2e, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x52, 0x06, 0x63, 0x6f,
+	0x75, 0x6e, 0x74, 0x73, 0x12, 0x20, 0x0a, 0x07, 0x74, 0x6f, 0x70, 0x4b, 0x65, 0x79, 0x73, 0x18,
+	0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x06, 0x2e, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x52, 0x07, 0x74,
+	0x6f, 0x70, 0x4b, 0x65, 0x79, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x65,
+	0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x65, 0x64,
+	0x12, 0x1c, 0x0a, 0x09, 0x74, 0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x64, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x09, 0x74, 0x72, 0x75, 0x6e, 0x63, 0x61, 0x74, 0x65, 0x64, 0x22, 0x69,
+	0x0a, 0x05, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x16, 0x0a, 0x03, 0x6c, 0x76, 0x6c,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x04, 0x2e, 0x4c, 0x76, 0x6c, 0x52, 0x03, 0x6c, 0x76,
+	0x6c, 0x12, 0x28, 0x0a, 0x01, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67,
+	0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x01, 0x74, 0x12, 0x0c, 0x0a, 0x01, 0x6e,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x01, 0x6e, 0x22, 0x33, 0x0a, 0x03, 0x41, 0x63, 0x6b,
+	0x12, 0x12, 0x0a, 0x04, 0x6e, 0x65, 0x78, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04,
+	0x6e, 0x65, 0x78, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6e, 0x67, 0x18,
+	0x02, 0x20, 0x03, 0x28, 0x0d, 0x52, 0x07, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6e, 0x67, 0x22, 0x8a,
+	0x01, 0x0a, 0x08, 0x46, 0x72, 0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x69, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x69,
+	0x6e, 0x64, 0x65, 0x78, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x69, 0x6e, 0x64, 0x65,
+	0x78, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x05, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x2e, 0x0a, 0x0b, 0x63,
+	0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x0c, 0x2e, 0x43, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x0b,
+	0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0xfd, 0x01, 0x0a, 0x03,
+	0x4d, 0x73, 0x67, 0x12, 0x28, 0x0a, 0x01, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x01, 0x74, 0x12, 0x25, 0x0a,
+	0x05, 0x61, 0x74, 0x74, 0x72, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0f, 0x2e, 0x4d,
+	0x73, 0x67, 0x2e, 0x41, 0x74, 0x74, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x05, 0x61,
+	0x74, 0x74, 0x72, 0x73, 0x12, 0x16, 0x0a, 0x03, 0x6c, 0x76, 0x6c, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x04, 0x2e, 0x4c, 0x76, 0x6c, 0x52, 0x03, 0x6c, 0x76, 0x6c, 0x12, 0x10, 0x0a, 0x03,
+	0x74, 0x78, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x74, 0x78, 0x74, 0x12, 0x10,
+	0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79,
+	0x12, 0x10, 0x0a, 0x03, 0x73, 0x65, 0x71, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x73,
+	0x65, 0x71, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x18, 0x0e, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x06, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x1a, 0x3f, 0x0a, 0x0a, 0x41, 0x74,
+	0x74, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x1b, 0x0a, 0x05, 0x76, 0x61,
+	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x05, 0x2e, 0x41, 0x74, 0x74, 0x72,
+	0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xa1, 0x05, 0x0a, 0x0b,
+	0x51, 0x75, 0x65, 0x72, 0x79, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x12, 0x1b, 0x0a, 0x06, 0x6f,
+	0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x00, 0x52, 0x06, 0x6f,
+	0x66, 0x66, 0x73, 0x65, 0x74, 0x88, 0x01, 0x01, 0x12, 0x19, 0x0a, 0x05, 0x6c, 0x69, 0x6d, 0x69,
+	0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x01, 0x52, 0x05, 0x6c, 0x69, 0x6d, 0x69, 0x74,
+	0x88, 0x01, 0x01, 0x12, 0x37, 0x0a, 0x06, 0x74, 0x53, 0x74, 0x61, 0x72, 0x74, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x48,
+	0x02, 0x52, 0x06, 0x74, 0x53, 0x74, 0x61, 0x72, 0x74, 0x88, 0x01, 0x01, 0x12, 0x33, 0x0a, 0x04,
+	0x74, 0x45, 0x6e, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d,
+	0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x48, 0x03, 0x52, 0x04, 0x74, 0x45, 0x6e, 0x64, 0x88, 0x01,
+	0x01, 0x12, 0x15, 0x0a, 0x03, 0x74, 0x78, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x48, 0x04,
+	0x52, 0x03, 0x74, 0x78, 0x74, 0x88, 0x01, 0x01, 0x12, 0x29, 0x0a, 0x0d, 0x74, 0x78, 0x74, 0x49,
+	0x67, 0x6e, 0x6f, 0x72, 0x65, 0x43, 0x61, 0x73, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x48,
+	0x05, 0x52, 0x0d, 0x74, 0x78, 0x74, 0x49, 0x67, 0x6e, 0x6f, 0x72, 0x65, 0x43, 0x61, 0x73, 0x65,
+	0x88, 0x01, 0x01, 0x12, 0x1f, 0x0a, 0x08, 0x74, 0x78, 0x74, 0x52, 0x65, 0x67, 0x65, 0x78, 0x18,
+	0x07, 0x20, 0x01, 0x28, 0x09, 0x48, 0x06, 0x52, 0x08, 0x74, 0x78, 0x74, 0x52, 0x65, 0x67, 0x65,
+	0x78, 0x88, 0x01, 0x01, 0x12, 0x1b, 0x0a, 0x03, 0x6c, 0x76, 0x6c, 0x18, 0x08, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x04, 0x2e, 0x4c, 0x76, 0x6c, 0x48, 0x07, 0x52, 0x03, 0x6c, 0x76, 0x6c, 0x88, 0x01,
+	0x01, 0x12, 0x21, 0x0a, 0x05, 0x61, 0x74, 0x74, 0x72, 0x73, 0x18, 0x09, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x0b, 0x2e, 0x41, 0x74, 0x74, 0x72, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x52, 0x05, 0x61,
+	0x74, 0x74, 0x72, 0x73, 0x12, 0x33, 0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73,
+	0x69, 0x6f, 0x6e, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0c, 0x2e, 0x43, 0x6f, 0x6d, 0x70,
+	0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x48, 0x08, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x70, 0x72,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x88, 0x01, 0x01, 0x12, 0x1b, 0x0a, 0x06, 0x77, 0x69, 0x6e,
+	0x64, 0x6f, 0x77, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x09, 0x52, 0x06, 0x77, 0x69, 0x6e,
+	0x64, 0x6f, 0x77, 0x88, 0x01, 0x01, 0x12, 0x21, 0x0a, 0x09, 0x6b, 0x65, 0x79, 0x50, 0x72, 0x65,
+	0x66, 0x69, 0x78, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x09, 0x48, 0x0a, 0x52, 0x09, 0x6b, 0x65, 0x79,
+	0x50, 0x72, 0x65, 0x66, 0x69, 0x78, 0x88, 0x01, 0x01, 0x12, 0x1b, 0x0a, 0x06, 0x63, 0x75, 0x72,
+	0x73, 0x6f, 0x72, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0c, 0x48, 0x0b, 0x52, 0x06, 0x63, 0x75, 0x72,
+	0x73, 0x6f, 0x72, 0x88, 0x01, 0x01, 0x12, 0x21, 0x0a, 0x05, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x18,
+	0x0f, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x06, 0x2e, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x48, 0x0c, 0x52,
+	0x05, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x88, 0x01, 0x01, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x6f, 0x66,
+	0x66, 0x73, 0x65, 0x74, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x42, 0x09,
+	0x0a, 0x07, 0x5f, 0x74, 0x53, 0x74, 0x61, 0x72, 0x74, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x74, 0x45,
+	0x6e, 0x64, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x74, 0x78, 0x74, 0x42, 0x10, 0x0a, 0x0e, 0x5f, 0x74,
+	0x78, 0x74, 0x49, 0x67, 0x6e, 0x6f, 0x72, 0x65, 0x43, 0x61, 0x73, 0x65, 0x42, 0x0b, 0x0a, 0x09,
+	0x5f, 0x74, 0x78, 0x74, 0x52, 0x65, 0x67, 0x65, 0x78, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x6c, 0x76,
+	0x6c, 0x42, 0x0e, 0x0a, 0x0c, 0x5f, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f,
+	0x6e, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x42, 0x0c, 0x0a, 0x0a,
+	0x5f, 0x6b, 0x65, 0x79, 0x50, 0x72, 0x65, 0x66, 0x69, 0x78, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x63,
+	0x75, 0x72, 0x73, 0x6f, 0x72, 0x42, 0x08, 0x0a, 0x06, 0x5f, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x22,
+	0x65, 0x0a, 0x04, 0x41, 0x74, 0x74, 0x72, 0x12, 0x12, 0x0a, 0x03, 0x73, 0x74, 0x72, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x03, 0x73, 0x74, 0x72, 0x12, 0x12, 0x0a, 0x03, 0x69,
+	0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x48, 0x00, 0x52, 0x03, 0x69, 0x6e, 0x74, 0x12,
+	0x16, 0x0a, 0x05, 0x66, 0x6c, 0x6f, 0x61, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x48, 0x00,
+	0x52, 0x05, 0x66, 0x6c, 0x6f, 0x61, 0x74, 0x12, 0x14, 0x0a, 0x04, 0x62, 0x6f, 0x6f, 0x6c, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x08, 0x48, 0x00, 0x52, 0x04, 0x62, 0x6f, 0x6f, 0x6c, 0x42, 0x07, 0x0a,
+	0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x7f, 0x0a, 0x0a, 0x41, 0x74, 0x74, 0x72, 0x46, 0x69,
+	0x6c, 0x74, 0x65, 0x72, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x1a, 0x0a, 0x02, 0x65, 0x71, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x05, 0x2e, 0x41, 0x74, 0x74, 0x72, 0x48, 0x00, 0x52, 0x02, 0x65, 0x71, 0x88,
+	0x01, 0x01, 0x12, 0x15, 0x0a, 0x03, 0x6d, 0x69, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x48,
+	0x01, 0x52, 0x03, 0x6d, 0x69, 0x6e, 0x88, 0x01, 0x01, 0x12, 0x15, 0x0a, 0x03, 0x6d, 0x61, 0x78,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x48, 0x02, 0x52, 0x03, 0x6d, 0x61, 0x78, 0x88, 0x01, 0x01,
+	0x42, 0x05, 0x0a, 0x03, 0x5f, 0x65, 0x71, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x6d, 0x69, 0x6e, 0x42,
+	0x06, 0x0a, 0x04, 0x5f, 0x6d, 0x61, 0x78, 0x22, 0xc1, 0x02, 0x0a, 0x05, 0x52, 0x65, 0x70, 0x6c,
+	0x79, 0x12, 0x18, 0x0a, 0x04, 0x6d, 0x73, 0x67, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x04, 0x2e, 0x4d, 0x73, 0x67, 0x52, 0x04, 0x6d, 0x73, 0x67, 0x73, 0x12, 0x10, 0x0a, 0x03, 0x73,
+	0x65, 0x71, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03, 0x73, 0x65, 0x71, 0x12, 0x19, 0x0a,
+	0x05, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x48, 0x00, 0x52, 0x05,
+	0x74, 0x6f, 0x74, 0x61, 0x6c, 0x88, 0x01, 0x01, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x69, 0x64, 0x12, 0x33, 0x0a, 0x09, 0x61, 0x67, 0x67, 0x72,
+	0x65, 0x67, 0x61, 0x74, 0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x41, 0x67,
+	0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x65, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x48, 0x01, 0x52,
+	0x09, 0x61, 0x67, 0x67, 0x72, 0x65, 0x67, 0x61, 0x74, 0x65, 0x88, 0x01, 0x01, 0x12, 0x2a, 0x0a,
+	0x08, 0x66, 0x72, 0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x09, 0x2e, 0x46, 0x72, 0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x48, 0x02, 0x52, 0x08, 0x66, 0x72,
+	0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74, 0x88, 0x01, 0x01, 0x12, 0x24, 0x0a, 0x06, 0x63, 0x75, 0x72,
+	0x73, 0x6f, 0x72, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x07, 0x2e, 0x43, 0x75, 0x72, 0x73,
+	0x6f, 0x72, 0x48, 0x03, 0x52, 0x06, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x88, 0x01, 0x01, 0x12,
+	0x18, 0x0a, 0x07, 0x64, 0x72, 0x6f, 0x70, 0x70, 0x65, 0x64, 0x18, 0x08, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x07, 0x64, 0x72, 0x6f, 0x70, 0x70, 0x65, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x65, 0x72, 0x72,
+	0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x65, 0x72, 0x72, 0x42, 0x08, 0x0a, 0x06, 0x5f,
+	0x74, 0x6f, 0x74, 0x61, 0x6c, 0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x61, 0x67, 0x67, 0x72, 0x65, 0x67,
+	0x61, 0x74, 0x65, 0x42, 0x0b, 0x0a, 0x09, 0x5f, 0x66, 0x72, 0x61, 0x67, 0x6d, 0x65, 0x6e, 0x74,
+	0x42, 0x09, 0x0a, 0x07, 0x5f, 0x63, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x22, 0x68, 0x0a, 0x06, 0x43,
+	0x75, 0x72, 0x73, 0x6f, 0x72, 0x12, 0x25, 0x0a, 0x04, 0x73, 0x65, 0x71, 0x73, 0x18, 0x01, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x43, 0x75, 0x72, 0x73, 0x6f, 0x72, 0x2e, 0x53, 0x65, 0x71,
+	0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x04, 0x73, 0x65, 0x71, 0x73, 0x1a, 0x37, 0x0a, 0x09,
+	0x53, 0x65, 0x71, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x3a, 0x02, 0x38, 0x01, 0x2a, 0x48, 0x0a, 0x04, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x09, 0x0a,
+	0x05, 0x57, 0x52, 0x49, 0x54, 0x45, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x54, 0x41, 0x49, 0x4c,
+	0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x50, 0x49, 0x4e, 0x47, 0x10, 0x02, 0x12, 0x09, 0x0a, 0x05,
+	0x51, 0x55, 0x45, 0x52, 0x59, 0x10, 0x03, 0x12, 0x07, 0x0a, 0x03, 0x41, 0x43, 0x4b, 0x10, 0x04,
+	0x12, 0x0d, 0x0a, 0x09, 0x41, 0x47, 0x47, 0x52, 0x45, 0x47, 0x41, 0x54, 0x45, 0x10, 0x05, 0x2a,
+	0x2d, 0x0a, 0x0b, 0x43, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x08,
+	0x0a, 0x04, 0x4e, 0x4f, 0x4e, 0x45, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x5a, 0x53, 0x54, 0x44,
+	0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x4e, 0x41, 0x50, 0x50, 0x59, 0x10, 0x02, 0x2a, 0x2b,
+	0x0a, 0x05, 0x4f, 0x72, 0x64, 0x65, 0x72, 0x12, 0x10, 0x0a, 0x0c, 0x4e, 0x45, 0x57, 0x45, 0x53,
+	0x54, 0x5f, 0x46, 0x49, 0x52, 0x53, 0x54, 0x10, 0x00, 0x12, 0x10, 0x0a, 0x0c, 0x4f, 0x4c, 0x44,
+	0x45, 0x53, 0x54, 0x5f, 0x46, 0x49, 0x52, 0x53, 0x54, 0x10, 0x01, 0x2a, 0x56, 0x0a, 0x03, 0x4c,
+	0x76, 0x6c, 0x12, 0x0f, 0x0a, 0x0b, 0x4c, 0x56, 0x4c, 0x5f, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57,
+	0x4e, 0x10, 0x00, 0x12, 0x09, 0x0a, 0x05, 0x54, 0x52, 0x41, 0x43, 0x45, 0x10, 0x01, 0x12, 0x09,
+	0x0a, 0x05, 0x44, 0x45, 0x42, 0x55, 0x47, 0x10, 0x02, 0x12, 0x08, 0x0a, 0x04, 0x49, 0x4e, 0x46,
+	0x4f, 0x10, 0x03, 0x12, 0x08, 0x0a, 0x04, 0x57, 0x41, 0x52, 0x4e, 0x10, 0x04, 0x12, 0x09, 0x0a,
+	0x05, 0x45, 0x52, 0x52, 0x4f, 0x52, 0x10, 0x05, 0x12, 0x09, 0x0a, 0x05, 0x46, 0x41, 0x54, 0x41,
+	0x4c, 0x10, 0x06, 0x42, 0x07, 0x5a, 0x05, 0x2e, 0x2f, 0x63, 0x6d, 0x64, 0x62, 0x06, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_cmd_proto_rawDescOnce sync.Once
+	file_cmd_proto_rawDescData = file_cmd_proto_rawDesc
+)
+
+func file_cmd_proto_rawDescGZIP() []byte {
+	file_cmd_proto_rawDescOnce.Do(func() {
+		file_cmd_proto_rawDescData = protoimpl.X.CompressGZIP(file_cmd_proto_rawDescData)
+	})
+	return file_cmd_proto_rawDescData
+}
+
+var file_cmd_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
+var file_cmd_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
+var file_cmd_proto_goTypes = []interface{}{
+	(Name)(0),                     // 0: Name
+	(Compression)(0),              // 1: Compression
+	(Order)(0),                    // 2: Order
+	(Lvl)(0),                      // 3: Lvl
+	(*Cmd)(nil),                   // 4: Cmd
+	(*Aggregation)(nil),           // 5: Aggregation
+	(*AggregateResult)(nil),       // 6: AggregateResult
+	(*Count)(nil),                 // 7: Count
+	(*Ack)(nil),                   // 8: Ack
+	(*Fragment)(nil),              // 9: Fragment
+	(*Msg)(nil),                   // 10: Msg
+	(*QueryParams)(nil),           // 11: QueryParams
+	(*Attr)(nil),                  // 12: Attr
+	(*AttrFilter)(nil),            // 13: AttrFilter
+	(*Reply)(nil),                 // 14: Reply
+	(*Cursor)(nil),                // 15: Cursor
+	nil,                           // 16: Msg.AttrsEntry
+	nil,                           // 17: Cursor.SeqsEntry
+	(*timestamppb.Timestamp)(nil), // 18: google.protobuf.Timestamp
+}
+var file_cmd_proto_depIdxs = []int32{
+	0,  // 0: Cmd.name:type_name -> Name
+	10, // 1: Cmd.msg:type_name -> Msg
+	11, // 2: Cmd.queryParams:type_name -> QueryParams
+	10, // 3: Cmd.msgs:type_name -> Msg
+	9,  // 4: Cmd.fragment:type_name -> Fragment
+	8,  // 5: Cmd.ack:type_name -> Ack
+	5,  // 6: Cmd.aggregation:type_name -> Aggregation
+	7,  // 7: AggregateResult.counts:type_name -> Count
+	7,  // 8: AggregateResult.topKeys:type_name -> Count
+	3,  // 9: Count.lvl:type_name -> Lvl
+	18, // 10: Count.t:type_name -> google.protobuf.Timestamp
+	1,  // 11: Fragment.compression:type_name -> Compression
+	18, // 12: Msg.t:type_name -> google.protobuf.Timestamp
+	16, // 13: Msg.attrs:type_name -> Msg.AttrsEntry
+	3,  // 14: Msg.lvl:type_name -> Lvl
+	18, // 15: QueryParams.tStart:type_name -> google.protobuf.Timestamp
+	18, // 16: QueryParams.tEnd:type_name -> google.protobuf.Timestamp
+	3,  // 17: QueryParams.lvl:type_name -> Lvl
+	13, // 18: QueryParams.attrs:type_name -> AttrFilter
+	1,  // 19: QueryParams.compression:type_name -> Compression
+	2,  // 20: QueryParams.order:type_name -> Order
+	12, // 21: AttrFilter.eq:type_name -> Attr
+	10, // 22: Reply.msgs:type_name -> Msg
+	6,  // 23: Reply.aggregate:type_name -> AggregateResult
+	9,  // 24: Reply.fragment:type_name -> Fragment
+	15, // 25: Reply.cursor:type_name -> Cursor
+	17, // 26: Cursor.seqs:type_name -> Cursor.SeqsEntry
+	12, // 27: Msg.AttrsEntry.value:type_name -> Attr
+	28, // [28:28] is the sub-list for method output_type
+	28, // [28:28] is the sub-list for method input_type
+	28, // [28:28] is the sub-list for extension type_name
+	28, // [28:28] is the sub-list for extension extendee
+	0,  // [0:28] is the sub-list for field type_name
+}
+
+func init() { file_cmd_proto_init() }
+func file_cmd_proto_init() {
+	if File_cmd_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_cmd_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Cmd); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cmd_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Aggregation); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
 			}
 		}
 		file_cmd_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AggregateResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cmd_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Count); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cmd_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Ack); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cmd_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Fragment); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cmd_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Msg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cmd_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*QueryParams); i {
 			case 0:
 				return &v.state
@@ -488,16 +1647,72 @@ func file_cmd_proto_init() {
 				return nil
 			}
 		}
+		file_cmd_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Attr); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cmd_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AttrFilter); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cmd_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Reply); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_cmd_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Cursor); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	file_cmd_proto_msgTypes[0].OneofWrappers = []interface{}{}
-	file_cmd_proto_msgTypes[2].OneofWrappers = []interface{}{}
+	file_cmd_proto_msgTypes[7].OneofWrappers = []interface{}{}
+	file_cmd_proto_msgTypes[8].OneofWrappers = []interface{}{
+		(*Attr_Str)(nil),
+		(*Attr_Int)(nil),
+		(*Attr_Float)(nil),
+		(*Attr_Bool)(nil),
+	}
+	file_cmd_proto_msgTypes[9].OneofWrappers = []interface{}{}
+	file_cmd_proto_msgTypes[10].OneofWrappers = []interface{}{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_cmd_proto_rawDesc,
-			NumEnums:      2,
-			NumMessages:   3,
+			NumEnums:      4,
+			NumMessages:   14,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -15,6 +15,8 @@ require (
 )
 
 require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/klauspost/compress v1.18.0
 	github.com/seiflotfy/cuckoofilter v0.0.0-20220411075957-e3b120b3f5fb
 	github.com/stretchr/testify v1.9.0
 	gopkg.in/yaml.v3 v3.0.1
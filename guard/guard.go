@@ -2,8 +2,10 @@ package guard
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
+	"sync/atomic"
 	"time"
 
 	"github.com/intob/logd/pkg"
@@ -12,8 +14,20 @@ import (
 
 type Guard struct {
 	filter    *cuckoo.Filter
+	filterCap uint
 	packetTtl time.Duration
 	quit      chan struct{}
+	filterLen atomic.Uint64
+	badSum    atomic.Uint64
+	stale     atomic.Uint64
+	replays   atomic.Uint64
+}
+
+// Rejections counts the packets rejected, by reason
+type Rejections struct {
+	Signature uint64 // unverifiable, or not signed by the secret
+	Stale     uint64 // outside of packet ttl
+	Replay    uint64
 }
 
 type Cfg struct {
@@ -25,6 +39,7 @@ type Cfg struct {
 func NewGuard(ctx context.Context, cfg *Cfg) *Guard {
 	g := &Guard{
 		filter:    cuckoo.NewFilter(cfg.FilterCap),
+		filterCap: cfg.FilterCap,
 		packetTtl: cfg.PacketTtl,
 		quit:      make(chan struct{}),
 	}
@@ -37,6 +52,7 @@ func NewGuard(ctx context.Context, cfg *Cfg) *Guard {
 				return
 			case <-time.After(cfg.FilterTtl):
 				g.filter.Reset()
+				g.filterLen.Store(0)
 			}
 		}
 	}()
@@ -44,24 +60,67 @@ func NewGuard(ctx context.Context, cfg *Cfg) *Guard {
 }
 
 func (g *Guard) Good(secret []byte, p *pkg.Pkg) bool {
-	authed, err := pkg.Verify(secret, g.packetTtl, p)
-	if err != nil || !authed {
+	return g.Signer(p, secret) == 0
+}
+
+// Signer returns the index of the first of secrets that signed p, or -1
+// if none did, or p is stale or a replay. As only the sum is verified,
+// it may be called before the payload is decompressed or unmarshaled.
+func (g *Guard) Signer(p *pkg.Pkg, secrets ...[]byte) int {
+	signer := -1
+	var err error
+	for i, secret := range secrets {
+		var authed bool
+		authed, err = pkg.Verify(secret, g.packetTtl, p)
+		if err != nil {
+			break // regardless of secret
+		}
+		if authed {
+			signer = i
+			break
+		}
+	}
+	if signer < 0 {
 		fmt.Printf("unauthorised: err: %v\n", err)
-		return false
+		if errors.Is(err, pkg.ErrStale) {
+			g.stale.Add(1)
+		} else {
+			g.badSum.Add(1)
+		}
+		return -1
 	}
 	if g.replay(p.Sum) {
+		g.replays.Add(1)
 		if rand.Intn(1000) < 1 {
 			fmt.Println("~1000 replays detected")
 		}
-		return false
+		return -1
 	}
-	return true
+	return signer
 }
 
 func (g *Guard) Quit() <-chan struct{} {
 	return g.quit
 }
 
+// Rejections returns the number of packets rejected, by reason
+func (g *Guard) Rejections() Rejections {
+	return Rejections{
+		Signature: g.badSum.Load(),
+		Stale:     g.stale.Load(),
+		Replay:    g.replays.Load(),
+	}
+}
+
+// Occupancy returns the number of sums in the filter, and its capacity
+func (g *Guard) Occupancy() (uint64, uint64) {
+	return g.filterLen.Load(), uint64(g.filterCap)
+}
+
 func (g *Guard) replay(sum []byte) bool {
-	return !g.filter.InsertUnique(sum)
+	if !g.filter.InsertUnique(sum) {
+		return true
+	}
+	g.filterLen.Add(1)
+	return false
 }
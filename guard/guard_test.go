@@ -37,6 +37,10 @@ func TestWithReplay(t *testing.T) {
 	}
 	require.True(t, guard.Good(secret, p), "Expected package to be good")
 	require.False(t, guard.Good(secret, p), "Expected package to be rejected due to replay")
+	require.Equal(t, Rejections{Replay: 1}, guard.Rejections())
+	n, capacity := guard.Occupancy()
+	require.Equal(t, uint64(1), n)
+	require.Equal(t, uint64(cfg.FilterCap), capacity)
 }
 
 func TestWrongSecret(t *testing.T) {
@@ -49,6 +53,37 @@ func TestWrongSecret(t *testing.T) {
 		Payload:   payload,
 		Sum:       sum,
 	}))
+	require.Equal(t, Rejections{Signature: 1}, guard.Rejections())
+}
+
+func TestSigner(t *testing.T) {
+	guard := NewGuard(context.Background(), cfg)
+	timeBytes, _ := time.Now().MarshalBinary()
+	payload := []byte("payload")
+	p := &pkg.Pkg{
+		TimeBytes: timeBytes,
+		Payload:   payload,
+		Sum:       calculateSum([]byte("read"), timeBytes, payload),
+	}
+	require.Equal(t, 1, guard.Signer(p, []byte("write"), []byte("read")))
+	require.Equal(t, -1, guard.Signer(p, []byte("write"), []byte("read")))
+	require.Equal(t, Rejections{Replay: 1}, guard.Rejections())
+	p.Sum = calculateSum([]byte("other"), timeBytes, payload)
+	require.Equal(t, -1, guard.Signer(p, []byte("write"), []byte("read")))
+	require.Equal(t, Rejections{Signature: 1, Replay: 1}, guard.Rejections())
+}
+
+func TestStale(t *testing.T) {
+	guard := NewGuard(context.Background(), cfg)
+	secret := []byte("secret")
+	timeBytes, _ := time.Now().Add(-cfg.PacketTtl - time.Second).MarshalBinary()
+	payload := []byte("payload")
+	require.False(t, guard.Good(secret, &pkg.Pkg{
+		TimeBytes: timeBytes,
+		Payload:   payload,
+		Sum:       calculateSum(secret, timeBytes, payload),
+	}))
+	require.Equal(t, Rejections{Stale: 1}, guard.Rejections())
 }
 
 func TestDone(t *testing.T) {
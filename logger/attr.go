@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intob/logd/cmd"
+)
+
+// Attr is a key and typed value, attached to a msg
+type Attr struct {
+	Key   string
+	Value *cmd.Attr
+}
+
+func String(key, value string) Attr {
+	return Attr{key, &cmd.Attr{Value: &cmd.Attr_Str{Str: value}}}
+}
+
+func Int(key string, value int) Attr {
+	return Int64(key, int64(value))
+}
+
+func Int64(key string, value int64) Attr {
+	return Attr{key, &cmd.Attr{Value: &cmd.Attr_Int{Int: value}}}
+}
+
+func Float(key string, value float64) Attr {
+	return Attr{key, &cmd.Attr{Value: &cmd.Attr_Float{Float: value}}}
+}
+
+func Bool(key string, value bool) Attr {
+	return Attr{key, &cmd.Attr{Value: &cmd.Attr_Bool{Bool: value}}}
+}
+
+// Duration is written as float seconds, so that it may be filtered by range
+func Duration(key string, value time.Duration) Attr {
+	return Float(key, value.Seconds())
+}
+
+func formatAttrs(attrs []Attr) string {
+	var b strings.Builder
+	for _, a := range attrs {
+		fmt.Fprintf(&b, " %s=%v", a.Key, attrValue(a.Value))
+	}
+	return b.String()
+}
+
+func attrValue(a *cmd.Attr) any {
+	switch v := a.GetValue().(type) {
+	case *cmd.Attr_Str:
+		return v.Str
+	case *cmd.Attr_Int:
+		return v.Int
+	case *cmd.Attr_Float:
+		return v.Float
+	case *cmd.Attr_Bool:
+		return v.Bool
+	}
+	return nil
+}
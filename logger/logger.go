@@ -3,6 +3,7 @@ package logger
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/intob/logd/client"
 	"github.com/intob/logd/cmd"
@@ -15,13 +16,18 @@ type Logger struct {
 	secret []byte
 	msgKey string
 	stdout bool
+	attrs  []Attr  // added to every msg
+	sender *sender // nil if synchronous
 }
 
 type LoggerCfg struct {
-	Client *client.Cfg `yaml:"client"`
-	Secret string      `yaml:"secret"`
-	MsgKey string      `yaml:"msg_key"`
-	Stdout bool        `yaml:"stdout"`
+	Client      *client.Cfg `yaml:"client"`
+	Secret      string      `yaml:"secret"`
+	MsgKey      string      `yaml:"msg_key"`
+	Stdout      bool        `yaml:"stdout"`
+	QueueSize   int         `yaml:"queue_size"`   // msgs, sent in the background if set
+	Overflow    string      `yaml:"overflow"`     // block, drop_newest, drop_oldest or sample
+	SampleEvery int         `yaml:"sample_every"` // keep 1 in n when sampling
 }
 
 func NewLogger(ctx context.Context, cfg *LoggerCfg) (*Logger, error) {
@@ -29,33 +35,95 @@ func NewLogger(ctx context.Context, cfg *LoggerCfg) (*Logger, error) {
 	if err != nil {
 		return nil, fmt.Errorf("err initializing client: %w", err)
 	}
-	return &Logger{
+	l := &Logger{
 		ctx:    ctx,
 		client: cl,
 		secret: []byte(cfg.Secret),
 		msgKey: cfg.MsgKey,
 		stdout: cfg.Stdout,
-	}, nil
+	}
+	if cfg.QueueSize > 0 {
+		l.sender, err = newSender(cfg.QueueSize, cfg.Overflow, cfg.SampleEvery, l.send)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
 }
 
 func (l *Logger) Log(lvl cmd.Lvl, template string, args ...interface{}) {
-	txt := fmt.Sprintf(template, args...)
-	signed, _ := l.client.SignCmd(l.ctx, &cmd.Cmd{
-		Name: cmd.Name_WRITE,
-		Msg: &cmd.Msg{
-			T:   timestamppb.Now(),
-			Key: l.msgKey,
-			Lvl: lvl,
-			Txt: txt,
-		},
-	}, l.secret)
-	l.client.Wait(l.ctx)
-	l.client.Write(signed)
+	l.LogAttrs(lvl, fmt.Sprintf(template, args...))
+}
+
+// LogAttrs writes txt with attrs, in addition to those of the logger
+func (l *Logger) LogAttrs(lvl cmd.Lvl, txt string, attrs ...Attr) {
+	l.write(time.Now(), lvl, txt, attrs)
+}
+
+func (l *Logger) write(t time.Time, lvl cmd.Lvl, txt string, attrs []Attr) {
+	msg := &cmd.Msg{
+		T:   timestamppb.New(t),
+		Key: l.msgKey,
+		Lvl: lvl,
+		Txt: txt,
+	}
+	if len(l.attrs)+len(attrs) > 0 {
+		msg.Attrs = make(map[string]*cmd.Attr, len(l.attrs)+len(attrs))
+		for _, a := range l.attrs {
+			msg.Attrs[a.Key] = a.Value
+		}
+		for _, a := range attrs {
+			msg.Attrs[a.Key] = a.Value
+		}
+	}
 	if l.stdout {
-		fmt.Printf(template+"\n", args...)
+		fmt.Println(txt + formatAttrs(l.attrs) + formatAttrs(attrs))
+	}
+	if l.sender == nil {
+		l.send([]*cmd.Msg{msg})
+		return
+	}
+	l.sender.enqueue(msg)
+}
+
+// send writes msgs, packed into as few packets as fit,
+// waiting for the rate limiter. It must not keep msgs.
+func (l *Logger) send(msgs []*cmd.Msg) {
+	err := l.client.WriteMsgs(l.ctx, msgs, l.secret)
+	if err != nil && l.stdout {
+		fmt.Println("err sending logs:", err)
 	}
 }
 
+// Flush waits until msgs logged before the call are sent
+func (l *Logger) Flush() {
+	if l.sender != nil {
+		l.sender.flush()
+	}
+}
+
+// Close flushes, and stops sending. Msgs logged after are dropped.
+func (l *Logger) Close() {
+	if l.sender != nil {
+		l.sender.close()
+	}
+}
+
+// Dropped returns the number of msgs dropped by the overflow policy
+func (l *Logger) Dropped() uint64 {
+	if l.sender == nil {
+		return 0
+	}
+	return l.sender.dropped.Load()
+}
+
+// With returns a logger that adds attrs to every msg
+func (l *Logger) With(attrs ...Attr) *Logger {
+	w := *l
+	w.attrs = append(append(make([]Attr, 0, len(l.attrs)+len(attrs)), l.attrs...), attrs...)
+	return &w
+}
+
 func (l *Logger) Error(template string, args ...interface{}) {
 	l.Log(cmd.Lvl_ERROR, template, args...)
 }
@@ -0,0 +1,158 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/intob/logd/cmd"
+)
+
+const (
+	OverflowBlock      = "block"       // wait for room in the queue
+	OverflowDropNewest = "drop_newest" // drop the msg being logged
+	OverflowDropOldest = "drop_oldest" // drop the oldest queued msg
+	OverflowSample     = "sample"      // over half full, keep 1 in sample_every
+	defaultSampleEvery = 10
+	maxBatch           = 64
+)
+
+// sender sends queued msgs in the background, so that logging
+// does not wait for the rate limiter or the socket
+type sender struct {
+	queue       chan *cmd.Msg
+	overflow    string
+	sampleEvery uint64
+	sampled     atomic.Uint64 // msgs offered while sampling
+	dropped     atomic.Uint64
+	mu          sync.RWMutex // held to enqueue, and exclusively to close
+	closed      bool
+	send        func([]*cmd.Msg)
+	flushReq    chan chan struct{}
+	quit        chan struct{}
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+func newSender(size int, overflow string, sampleEvery int, send func([]*cmd.Msg)) (*sender, error) {
+	switch overflow {
+	case "":
+		overflow = OverflowBlock
+	case OverflowBlock, OverflowDropNewest, OverflowDropOldest, OverflowSample:
+	default:
+		return nil, fmt.Errorf("invalid overflow policy %q", overflow)
+	}
+	if sampleEvery <= 0 {
+		sampleEvery = defaultSampleEvery
+	}
+	s := &sender{
+		queue:       make(chan *cmd.Msg, size),
+		overflow:    overflow,
+		sampleEvery: uint64(sampleEvery),
+		send:        send,
+		flushReq:    make(chan chan struct{}),
+		quit:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// enqueue queues msg, applying the overflow policy if the queue is full
+func (s *sender) enqueue(msg *cmd.Msg) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		s.dropped.Add(1)
+		return
+	}
+	switch s.overflow {
+	case OverflowBlock:
+		select {
+		case s.queue <- msg:
+		case <-s.done:
+			s.dropped.Add(1)
+		}
+		return
+	case OverflowDropOldest:
+		for {
+			select {
+			case s.queue <- msg:
+				return
+			default:
+			}
+			select {
+			case <-s.queue:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	case OverflowSample:
+		if len(s.queue) > cap(s.queue)/2 && s.sampled.Add(1)%s.sampleEvery != 0 {
+			s.dropped.Add(1)
+			return
+		}
+	}
+	select {
+	case s.queue <- msg:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+func (s *sender) run() {
+	defer close(s.done)
+	batch := make([]*cmd.Msg, 0, maxBatch)
+	for {
+		select {
+		case msg := <-s.queue:
+			batch = s.drain(append(batch, msg), maxBatch)
+			s.send(batch)
+			batch = batch[:0]
+		case req := <-s.flushReq:
+			for len(s.queue) > 0 {
+				batch = s.drain(batch, maxBatch)
+				s.send(batch)
+				batch = batch[:0]
+			}
+			close(req)
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// drain appends queued msgs to batch, without waiting, up to limit
+func (s *sender) drain(batch []*cmd.Msg, limit int) []*cmd.Msg {
+	for len(batch) < limit {
+		select {
+		case msg := <-s.queue:
+			batch = append(batch, msg)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// flush waits until the msgs queued before the call are sent
+func (s *sender) flush() {
+	req := make(chan struct{})
+	select {
+	case s.flushReq <- req:
+		<-req
+	case <-s.done:
+	}
+}
+
+// close flushes, then stops the sender. Msgs logged after are dropped.
+func (s *sender) close() {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		s.flush()
+		close(s.quit)
+		<-s.done
+	})
+}
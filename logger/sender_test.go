@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/intob/logd/cmd"
+	"github.com/stretchr/testify/require"
+)
+
+// blockedSender returns a sender whose first send blocks until
+// unblock is called, and the txts of the msgs sent
+func blockedSender(t *testing.T, size int, overflow string) (*sender, func(), func() []string) {
+	var mu sync.Mutex
+	var sent []string
+	gate := make(chan struct{})
+	started := make(chan struct{})
+	var once sync.Once
+	s, err := newSender(size, overflow, 2, func(msgs []*cmd.Msg) {
+		once.Do(func() {
+			close(started)
+			<-gate
+		})
+		mu.Lock()
+		defer mu.Unlock()
+		for _, m := range msgs {
+			sent = append(sent, m.Txt)
+		}
+	})
+	require.NoError(t, err)
+	// the first msg is held by the blocked send
+	s.enqueue(&cmd.Msg{Txt: "first"})
+	<-started
+	return s, func() { close(gate) }, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return sent
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	cases := map[string][]string{
+		OverflowDropNewest: {"first", "0", "1", "2", "3"},
+		OverflowDropOldest: {"first", "6", "7", "8", "9"},
+		OverflowSample:     {"first", "0", "1", "2", "4"},
+	}
+	for overflow, want := range cases {
+		s, unblock, sent := blockedSender(t, 4, overflow)
+		for i := 0; i < 10; i++ {
+			s.enqueue(&cmd.Msg{Txt: fmt.Sprintf("%d", i)})
+		}
+		unblock()
+		s.close()
+		require.Equal(t, want, sent(), overflow)
+		require.Equal(t, uint64(6), s.dropped.Load(), overflow)
+	}
+}
+
+func TestBlockAndClose(t *testing.T) {
+	s, unblock, sent := blockedSender(t, 1, OverflowBlock)
+	s.enqueue(&cmd.Msg{Txt: "0"})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.enqueue(&cmd.Msg{Txt: "1"}) // waits for room
+	}()
+	unblock()
+	<-done
+	s.flush()
+	require.Equal(t, []string{"first", "0", "1"}, sent())
+	s.close()
+	s.enqueue(&cmd.Msg{Txt: "2"})
+	require.Equal(t, uint64(1), s.dropped.Load())
+	_, err := newSender(1, "bogus", 0, nil)
+	require.Error(t, err)
+}
+
+func TestCloseWhileLogging(t *testing.T) {
+	var sent atomic.Uint64
+	s, err := newSender(4, OverflowBlock, 0, func(msgs []*cmd.Msg) {
+		sent.Add(uint64(len(msgs)))
+	})
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				s.enqueue(&cmd.Msg{Txt: "msg"})
+			}
+		}()
+	}
+	s.close()
+	wg.Wait()
+	// each msg is either sent before close returns, or counted as dropped
+	require.Equal(t, uint64(800), sent.Load()+s.dropped.Load())
+}
@@ -0,0 +1,124 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/intob/logd/cmd"
+)
+
+// Handler is a slog.Handler that writes through a Logger.
+// Attributes of groups are flattened, with keys joined by dots.
+type Handler struct {
+	l      *Logger
+	level  slog.Leveler
+	prefix string // of keys, from groups
+}
+
+// NewHandler returns a slog.Handler writing through l, enabled from
+// level, or slog.LevelInfo if nil. Use it as the default logger with
+// slog.SetDefault(slog.New(logger.NewHandler(l, nil))).
+func NewHandler(l *Logger, level slog.Leveler) *Handler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &Handler{l: l, level: level}
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	attrs := make([]Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = appendSlogAttr(attrs, h.prefix, a)
+		return true
+	})
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	h.l.write(t, lvlOf(r.Level), r.Message, attrs)
+	return nil
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	converted := make([]Attr, 0, len(attrs))
+	for _, a := range attrs {
+		converted = appendSlogAttr(converted, h.prefix, a)
+	}
+	w := *h
+	w.l = h.l.With(converted...)
+	return &w
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	w := *h
+	w.prefix = h.prefix + name + "."
+	return &w
+}
+
+// lvlOf maps a slog level to the nearest cmd.Lvl at or below it
+func lvlOf(level slog.Level) cmd.Lvl {
+	switch {
+	case level >= slog.LevelError:
+		return cmd.Lvl_ERROR
+	case level >= slog.LevelWarn:
+		return cmd.Lvl_WARN
+	case level >= slog.LevelInfo:
+		return cmd.Lvl_INFO
+	case level >= slog.LevelDebug:
+		return cmd.Lvl_DEBUG
+	}
+	return cmd.Lvl_TRACE
+}
+
+// appendSlogAttr converts a, flattening groups, as slog handlers
+// must: empty attrs are dropped, and groups without a key inlined
+func appendSlogAttr(attrs []Attr, prefix string, a slog.Attr) []Attr {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		group := v.Group()
+		if len(group) == 0 {
+			return attrs
+		}
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range group {
+			attrs = appendSlogAttr(attrs, prefix, ga)
+		}
+		return attrs
+	}
+	if a.Equal(slog.Attr{}) {
+		return attrs
+	}
+	key := prefix + a.Key
+	switch v.Kind() {
+	case slog.KindString:
+		return append(attrs, String(key, v.String()))
+	case slog.KindInt64:
+		return append(attrs, Int64(key, v.Int64()))
+	case slog.KindUint64:
+		if v.Uint64() <= math.MaxInt64 {
+			return append(attrs, Int64(key, int64(v.Uint64())))
+		}
+		return append(attrs, Float(key, float64(v.Uint64())))
+	case slog.KindFloat64:
+		return append(attrs, Float(key, v.Float64()))
+	case slog.KindBool:
+		return append(attrs, Bool(key, v.Bool()))
+	case slog.KindDuration:
+		return append(attrs, Duration(key, v.Duration()))
+	case slog.KindTime:
+		return append(attrs, String(key, v.Time().Format(time.RFC3339Nano)))
+	}
+	return append(attrs, String(key, fmt.Sprint(v.Any())))
+}
@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlogAttrs(t *testing.T) {
+	in := []slog.Attr{
+		slog.String("user", "joey"),
+		slog.Int("status", 503),
+		slog.Duration("dur", 1500*time.Millisecond),
+		slog.Group("req", slog.String("method", "GET"), slog.Group("", slog.Bool("tls", true))),
+		slog.Group("empty"),
+		{},
+	}
+	var attrs []Attr
+	for _, a := range in {
+		attrs = appendSlogAttr(attrs, "g.", a)
+	}
+	got := make(map[string]any)
+	for _, a := range attrs {
+		got[a.Key] = attrValue(a.Value)
+	}
+	require.Equal(t, map[string]any{
+		"g.user":       "joey",
+		"g.status":     int64(503),
+		"g.dur":        1.5,
+		"g.req.method": "GET",
+		"g.req.tls":    true,
+	}, got)
+}
+
+func TestLvlOf(t *testing.T) {
+	require.Equal(t, cmd.Lvl_TRACE, lvlOf(slog.LevelDebug-1))
+	require.Equal(t, cmd.Lvl_DEBUG, lvlOf(slog.LevelDebug))
+	require.Equal(t, cmd.Lvl_INFO, lvlOf(slog.LevelInfo+1))
+	require.Equal(t, cmd.Lvl_WARN, lvlOf(slog.LevelWarn))
+	require.Equal(t, cmd.Lvl_ERROR, lvlOf(slog.LevelError+4))
+}
+
+func TestHandlerGroups(t *testing.T) {
+	h := NewHandler(&Logger{}, nil)
+	require.False(t, h.Enabled(context.Background(), slog.LevelDebug))
+	g := h.WithGroup("a").WithGroup("b").(*Handler)
+	require.Equal(t, "a.b.", g.prefix)
+	w := g.WithAttrs([]slog.Attr{slog.Int("n", 1)}).(*Handler)
+	require.Equal(t, "a.b.n", w.l.attrs[0].Key)
+	require.Empty(t, h.l.attrs)
+}
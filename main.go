@@ -36,9 +36,10 @@ func main() {
 	fmt.Println("🌱 running", string(commit))
 	config := &Cfg{
 		Udp: &udp.Cfg{
-			LaddrPort:        ":6102",
-			PacketBufferSize: 1460,  // typical WiFi MTU, feel free to increase
-			QueryHardLimit:   50000, // maybe use offset
+			LaddrPort:          ":6102",
+			PacketBufferSize:   1460,  // typical WiFi MTU, feel free to increase
+			QueryHardLimit:     50000, // maybe use offset
+			AggregateHardLimit: 1000000,
 			Secrets: &udp.Secrets{
 				Read:  "gold",
 				Write: "bitcoin",
@@ -75,10 +76,14 @@ func main() {
 		config.Udp.Secrets = sec
 		fmt.Printf("secrets loaded from %q\n", secretsFile)
 	}
-	logStore := store.NewStore(config.Store)
+	logStore, err := store.NewStore(config.Store)
+	if err != nil {
+		panic(fmt.Sprintf("failed to open store: %v", err))
+	}
 	config.App.LogStore = logStore
 	config.Udp.LogStore = logStore
-	udp.NewSvc(ctx, config.Udp)
+	config.App.Secrets = config.Udp.Secrets
+	config.App.UdpSvc = udp.NewSvc(ctx, config.Udp)
 	app.NewApp(ctx, config.App)
 	fmt.Println("read secret sha256:", secretHash(config.Udp.Secrets.Read))
 	fmt.Println("write secret sha256:", secretHash(config.Udp.Secrets.Write))
@@ -86,6 +91,10 @@ func main() {
 	fmt.Printf("udp guard: %+v\n", config.Udp.Guard)
 	<-ctx.Done()
 	<-time.After(time.Millisecond)
+	err = logStore.Close()
+	if err != nil {
+		fmt.Println("err closing store:", err)
+	}
 	fmt.Println("logd ended")
 }
 
@@ -0,0 +1,117 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/intob/logd/cmd"
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// A packed payload begins with a flag byte, of flagPacked with the
+// compression in the low bits. Replies are always packed. Cmds are
+// packed only if compressed, so that the packets of older clients
+// still parse. As the fields of Cmd are numbered below 16, the first
+// byte of an unpacked cmd is a tag below flagPacked.
+const (
+	flagPacked          = 0x80
+	MaxDecompressedSize = 1 << 20
+)
+
+var (
+	zstdEnc = sync.OnceValues(func() (*zstd.Encoder, error) {
+		return zstd.NewWriter(nil)
+	})
+	zstdDec = sync.OnceValues(func() (*zstd.Decoder, error) {
+		return zstd.NewReader(nil,
+			zstd.WithDecoderConcurrency(0),
+			zstd.WithDecoderMaxMemory(MaxDecompressedSize))
+	})
+)
+
+// Compress returns the payload compressed by c, or as is if c is NONE
+func Compress(c cmd.Compression, payload []byte) ([]byte, error) {
+	switch c {
+	case cmd.Compression_NONE:
+		return payload, nil
+	case cmd.Compression_ZSTD:
+		enc, err := zstdEnc()
+		if err != nil {
+			return nil, fmt.Errorf("err initializing zstd encoder: %w", err)
+		}
+		return enc.EncodeAll(payload, nil), nil
+	case cmd.Compression_SNAPPY:
+		return s2.EncodeSnappy(nil, payload), nil
+	}
+	return nil, fmt.Errorf("unknown compression %d", c)
+}
+
+// Decompress returns data decompressed by c. The
+// decompressed size is limited to MaxDecompressedSize.
+func Decompress(c cmd.Compression, data []byte) ([]byte, error) {
+	switch c {
+	case cmd.Compression_NONE:
+		if len(data) > MaxDecompressedSize {
+			return nil, errors.New("decompressed size too large")
+		}
+		return data, nil
+	case cmd.Compression_ZSTD:
+		dec, err := zstdDec()
+		if err != nil {
+			return nil, fmt.Errorf("err initializing zstd decoder: %w", err)
+		}
+		out, err := dec.DecodeAll(data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("err decoding zstd: %w", err)
+		}
+		return out, nil
+	case cmd.Compression_SNAPPY:
+		n, err := s2.DecodedLen(data)
+		if err != nil {
+			return nil, fmt.Errorf("err decoding snappy: %w", err)
+		}
+		if n > MaxDecompressedSize {
+			return nil, errors.New("decompressed size too large")
+		}
+		out, err := s2.Decode(nil, data)
+		if err != nil {
+			return nil, fmt.Errorf("err decoding snappy: %w", err)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unknown compression %d", c)
+}
+
+// Pack returns the payload of a reply compressed by c, behind the
+// flag byte, so that a reply is not mistaken for a msg
+func Pack(c cmd.Compression, payload []byte) ([]byte, error) {
+	packed, err := Compress(c, payload)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{flagPacked | byte(c)}, packed...), nil
+}
+
+// IsPacked returns true if data begins with the flag byte
+func IsPacked(data []byte) bool {
+	return len(data) > 0 && data[0]&flagPacked != 0
+}
+
+// Unpacked returns the payload of a reply packed by Pack
+func Unpacked(data []byte) ([]byte, error) {
+	if !IsPacked(data) {
+		return nil, errors.New("payload is not packed")
+	}
+	return Decompress(cmd.Compression(data[0]&^flagPacked), data[1:])
+}
+
+// Flagged returns the compression flagged by the payload of a cmd,
+// and the payload after the flag, or NONE and the payload as is
+func Flagged(payload []byte) (cmd.Compression, []byte) {
+	if !IsPacked(payload) {
+		return cmd.Compression_NONE, payload
+	}
+	return cmd.Compression(payload[0] &^ flagPacked), payload[1:]
+}
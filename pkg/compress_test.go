@@ -0,0 +1,48 @@
+package pkg
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/intob/logd/cmd"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	payload, err := proto.Marshal(&cmd.Cmd{Name: cmd.Name_WRITE, Msg: &cmd.Msg{
+		Key: "/test/app",
+		Txt: `{"level":"info","msg":"served","path":"/api/v1/things","status":200}`,
+	}})
+	require.NoError(t, err)
+	require.False(t, IsPacked(payload))
+	for _, c := range []cmd.Compression{cmd.Compression_ZSTD, cmd.Compression_SNAPPY} {
+		compressed, err := Compress(c, bytes.Repeat(payload, 20))
+		require.NoError(t, err)
+		require.Less(t, len(compressed), len(payload)*20, c)
+		raw, err := Decompress(c, compressed)
+		require.NoError(t, err)
+		require.Equal(t, bytes.Repeat(payload, 20), raw, c)
+	}
+	for _, c := range []cmd.Compression{cmd.Compression_NONE, cmd.Compression_ZSTD} {
+		packed, err := Pack(c, payload)
+		require.NoError(t, err)
+		require.True(t, IsPacked(packed), c)
+		raw, err := Unpacked(packed)
+		require.NoError(t, err)
+		require.Equal(t, payload, raw, c)
+	}
+	_, err = Unpacked(payload)
+	require.Error(t, err)
+}
+
+func TestDecompressLimit(t *testing.T) {
+	for _, c := range []cmd.Compression{cmd.Compression_ZSTD, cmd.Compression_SNAPPY} {
+		compressed, err := Compress(c, make([]byte, MaxDecompressedSize+1))
+		require.NoError(t, err)
+		_, err = Decompress(c, compressed)
+		require.Error(t, err, c)
+	}
+	_, err := Decompress(9, []byte{1, 2, 3})
+	require.Error(t, err)
+}
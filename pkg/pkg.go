@@ -6,8 +6,16 @@ import (
 	"errors"
 	"fmt"
 	"time"
+
+	"github.com/intob/logd/cmd"
 )
 
+// HeaderSize is the size of a pkg before the payload
+const HeaderSize = 32 /* sha256 */ + 15 /* time */
+
+// ErrStale is returned by Verify if the time of the pkg is outside of ttl
+var ErrStale = errors.New("time is outside of threshold")
+
 type Pkg struct {
 	Sum, TimeBytes, Payload []byte
 }
@@ -15,12 +23,12 @@ type Pkg struct {
 // Unpack unpacks data into pkg.
 // This approach allows caller to allocate pkg efficiently.
 func Unpack(data []byte, pkg *Pkg) error {
-	if len(data) < 32+15 {
+	if len(data) < HeaderSize {
 		return errors.New("data too short")
 	}
 	pkg.Sum = data[:32]              /*32B sha256*/
 	pkg.TimeBytes = data[32 : 32+15] /*15B time*/
-	pkg.Payload = data[32+15:]
+	pkg.Payload = data[32+15:]       /* payload */
 	return nil
 }
 
@@ -36,6 +44,15 @@ func Sign(secret, payload []byte) []byte {
 	return append(data, payload...)
 }
 
+// SignCompressed signs a payload compressed by c, behind the flag
+// byte of c, which the sum covers. Uncompressed, it is as Sign.
+func SignCompressed(secret []byte, c cmd.Compression, payload []byte) []byte {
+	if c == cmd.Compression_NONE {
+		return Sign(secret, payload)
+	}
+	return Sign(secret, append([]byte{flagPacked | byte(c)}, payload...))
+}
+
 func Verify(secret []byte, ttl time.Duration, p *Pkg) (bool, error) {
 	var t time.Time
 	err := t.UnmarshalBinary(p.TimeBytes)
@@ -43,7 +60,7 @@ func Verify(secret []byte, ttl time.Duration, p *Pkg) (bool, error) {
 		return false, fmt.Errorf("err unmarshaling time: %w", err)
 	}
 	if t.After(time.Now()) || t.Before(time.Now().Add(-ttl)) {
-		return false, errors.New("time is outside of threshold")
+		return false, ErrStale
 	}
 	totalLen := len(secret) + len(p.TimeBytes) + len(p.Payload)
 	data := make([]byte, 0, totalLen)
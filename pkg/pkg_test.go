@@ -3,6 +3,9 @@ package pkg
 import (
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/intob/logd/cmd"
 )
 
 func TestUnpackValidData(t *testing.T) {
@@ -31,3 +34,33 @@ func TestUnpackDataTooShort(t *testing.T) {
 		t.Errorf("Unpack() error = %v, wantErr %v", err, true)
 	}
 }
+
+func TestSignCompressed(t *testing.T) {
+	secret := []byte("secret")
+	// uncompressed, as packets of older clients, without a flag
+	var p Pkg
+	if err := Unpack(SignCompressed(secret, cmd.Compression_NONE, []byte{0x08, 0x01}), &p); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := Verify(secret, time.Second, &p); !ok || err != nil {
+		t.Fatalf("Verify() = %v, %v", ok, err)
+	}
+	if c, payload := Flagged(p.Payload); c != cmd.Compression_NONE || !reflect.DeepEqual(payload, []byte{0x08, 0x01}) {
+		t.Errorf("Flagged() = %v, %v", c, payload)
+	}
+	// compressed, behind the flag, covered by the sum
+	data := SignCompressed(secret, cmd.Compression_ZSTD, []byte("zstd"))
+	if err := Unpack(data, &p); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := Verify(secret, time.Second, &p); !ok || err != nil {
+		t.Fatalf("Verify() = %v, %v", ok, err)
+	}
+	if c, payload := Flagged(p.Payload); c != cmd.Compression_ZSTD || string(payload) != "zstd" {
+		t.Errorf("Flagged() = %v, %q", c, payload)
+	}
+	data[HeaderSize] = flagPacked | byte(cmd.Compression_SNAPPY)
+	if ok, _ := Verify(secret, time.Second, &p); ok {
+		t.Error("Verify() = true for a changed flag")
+	}
+}
@@ -0,0 +1,173 @@
+package ring
+
+import (
+	"sync"
+	"time"
+)
+
+// ByteRing is a ring bounded by total bytes, rather than number of
+// entries. Payloads are copied into one contiguous arena, and located
+// by a table of spans. The oldest entries are evicted to make room.
+type ByteRing struct {
+	mu    sync.RWMutex
+	arena []byte
+	head  uint32 // arena position of next write
+	spans []span // circular table of entries, oldest at first
+	first int
+	n     int
+	seq   uint64 // number of the next write
+}
+
+type span struct {
+	off, len uint32
+	t        int64 // unix nano, when written
+}
+
+func (s span) end() uint32 {
+	// count empty entries as 1 byte, to keep the table bounded
+	return s.off + max(s.len, 1)
+}
+
+func (s span) overlaps(off, end uint32) bool {
+	return s.off < end && off < s.end()
+}
+
+// NewByteRing returns a pointer to a new ByteRing of size bytes
+func NewByteRing(size uint32) *ByteRing {
+	return &ByteRing{
+		arena: make([]byte, size),
+		spans: make([]span, 64),
+	}
+}
+
+// Size returns the size of the arena in bytes
+func (b *ByteRing) Size() uint32 {
+	return uint32(len(b.arena))
+}
+
+// Head returns the arena position of the next write
+func (b *ByteRing) Head() uint32 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.head
+}
+
+// Len returns the number of entries held
+func (b *ByteRing) Len() uint32 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return uint32(b.n)
+}
+
+// Seq returns the number of the next write
+func (b *ByteRing) Seq() uint64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.seq
+}
+
+// SetSeq sets the number of the next write.
+// It must be called before any writes.
+func (b *ByteRing) SetSeq(seq uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq = seq
+}
+
+// Get returns a copy of write number seq, if still held
+func (b *ByteRing) Get(seq uint64) ([]byte, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	oldest := b.seq - uint64(b.n)
+	if seq < oldest || seq >= b.seq {
+		return nil, false
+	}
+	return b.copy(b.first + int(seq-oldest)), true
+}
+
+// Time returns the time of write number seq, if still held
+func (b *ByteRing) Time(seq uint64) (time.Time, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	oldest := b.seq - uint64(b.n)
+	if seq < oldest || seq >= b.seq {
+		return time.Time{}, false
+	}
+	s := b.spans[(b.first+int(seq-oldest))%len(b.spans)]
+	return time.Unix(0, s.t), true
+}
+
+func (b *ByteRing) copy(i int) []byte {
+	s := b.spans[i%len(b.spans)]
+	data := make([]byte, s.len)
+	copy(data, b.arena[s.off:s.off+s.len])
+	return data
+}
+
+// Write copies data, written at t, into the arena, evicting the oldest
+// entries that it overlaps. Data larger than the arena empties the
+// ring, so that the entries held remain consecutive writes.
+func (b *ByteRing) Write(t time.Time, data []byte) {
+	size := uint32(len(b.arena))
+	l := uint32(len(data))
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	if l > size || size == 0 {
+		b.first, b.n = 0, 0
+		return
+	}
+	s := span{off: b.head, len: l, t: t.UnixNano()}
+	if s.end() > size {
+		// doesn't fit before the end, so wrap around, evicting
+		// the entries between head and end, as they are oldest
+		b.evict(b.head, size)
+		s.off = 0
+	}
+	b.evict(s.off, s.end())
+	copy(b.arena[s.off:], data)
+	b.push(s)
+	b.head = s.end() % size
+}
+
+// evict removes the oldest entries while they overlap off to end
+func (b *ByteRing) evict(off, end uint32) {
+	for b.n > 0 && b.spans[b.first].overlaps(off, end) {
+		b.first = (b.first + 1) % len(b.spans)
+		b.n--
+	}
+}
+
+// push appends s to the table, growing it if full
+func (b *ByteRing) push(s span) {
+	if b.n == len(b.spans) {
+		spans := make([]span, len(b.spans)*2)
+		for i := 0; i < b.n; i++ {
+			spans[i] = b.spans[(b.first+i)%len(b.spans)]
+		}
+		b.spans = spans
+		b.first = 0
+	}
+	b.spans[(b.first+b.n)%len(b.spans)] = s
+	b.n++
+}
+
+// Read reads up to limit entries, newest first, from offset.
+// Entries are copied, as the arena is reused.
+func (b *ByteRing) Read(offset, limit uint32) <-chan []byte {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if int(offset) >= b.n {
+		ch := make(chan []byte)
+		close(ch)
+		return ch
+	}
+	limit = min(limit, uint32(b.n)-offset)
+	ch := make(chan []byte, limit)
+	defer close(ch)
+	newest := b.first + b.n - 1
+	for i := uint32(0); i < limit; i++ {
+		ch <- b.copy(newest - int(offset+i))
+	}
+	return ch
+}
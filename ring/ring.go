@@ -1,20 +1,35 @@
 package ring
 
 import (
+	"runtime"
 	"sync/atomic"
+	"time"
 )
 
+// Ring is a fixed number of slots, overwritten in turn.
+// It is safe for concurrent writers, and readers never block.
 type Ring struct {
-	head   atomic.Uint32
+	head   atomic.Uint64 // number of writes, including those in progress
+	stored atomic.Uint64 // number of writes stored, moved in order of write
+	base   uint64        // number of the first write
 	size   uint32
-	values [][]byte
+	values []atomic.Pointer[entry]
+}
+
+// entry is the value of a slot, with the number of
+// the write that stored it, so that readers can tell
+// a current value from a stale or overwritten one
+type entry struct {
+	n    uint64
+	t    int64 // unix nano, when written
+	data []byte
 }
 
 // NewRingBuffer returns a pointer to a new RingBuffer of given size
 func NewRing(size uint32) *Ring {
 	r := &Ring{
 		size:   size,
-		values: make([][]byte, size),
+		values: make([]atomic.Pointer[entry], size),
 	}
 	return r
 }
@@ -23,58 +38,93 @@ func (b *Ring) Size() uint32 {
 	return b.size
 }
 
-// Write writes the data to buffer at position of head,
-// head is then atomically incremented
-func (b *Ring) Write(data []byte) {
-	head := b.head.Load()
-	b.values[head] = data
-	b.head.Store((head + 1) % b.size)
+// Write reserves the slot at head by atomically incrementing it,
+// then stores the data written at t, unless a later write already has.
+// Once the writes before it are stored, it moves stored past it.
+func (b *Ring) Write(t time.Time, data []byte) {
+	n := b.head.Add(1) - 1
+	e := &entry{n: n, t: t.UnixNano(), data: data}
+	slot := &b.values[n%uint64(b.size)]
+	for {
+		old := slot.Load()
+		if old != nil && old.n > n {
+			break // lapped by a faster writer
+		}
+		if slot.CompareAndSwap(old, e) {
+			break
+		}
+	}
+	for !b.stored.CompareAndSwap(n, n+1) {
+		runtime.Gosched()
+	}
 }
 
+// Read reads up to limit values, newest first, from offset.
+// Writes still in progress are skipped.
 func (b *Ring) Read(offset, limit uint32) <-chan []byte {
 	ch := make(chan []byte, limit) // Buffered channel with size 'limit'
 
 	go func() {
 		defer close(ch) // Ensure channel is closed when operation completes
 
-		if limit > b.size {
-			limit = b.size
-		}
-		reads := uint32(0)
 		head := b.head.Load()
-
-		var index uint32
-		// Calculate start index based on offset, ensuring it wraps correctly
-		if head < offset {
-			index = b.size - (offset - head)
-		} else {
-			index = head - offset - 1
-		}
-
-		// Adjust if we're starting beyond the most recent write
-		if index == b.size {
-			index = 0
-		}
-
-		// Loop to send data through the channel
-		for reads < limit {
-			// Calculate the correct index to read from, wrapping around if necessary
-			readIndex := (index - reads) % b.size
-			if b.values[readIndex] == nil {
-				break
+		for i := uint64(offset); i < uint64(offset)+uint64(limit); i++ {
+			if i >= head-b.base || i >= uint64(b.size) {
+				return
+			}
+			n := head - 1 - i
+			e := b.values[n%uint64(b.size)].Load()
+			if e == nil || e.n < n {
+				continue // not yet written
 			}
-			ch <- b.values[readIndex]
-			reads++
-			// Stop if we've looped back to the head
-			if reads >= limit || readIndex == head {
-				break
+			if e.n > n {
+				return // overwritten since we started
 			}
+			ch <- e.data
 		}
 	}()
 
 	return ch
 }
 
+// Get returns the value of write number seq, if still held
+func (b *Ring) Get(seq uint64) ([]byte, bool) {
+	e := b.values[seq%uint64(b.size)].Load()
+	if e == nil || e.n != seq {
+		return nil, false
+	}
+	return e.data, true
+}
+
+// Time returns the time of write number seq, if still held
+func (b *Ring) Time(seq uint64) (time.Time, bool) {
+	e := b.values[seq%uint64(b.size)].Load()
+	if e == nil || e.n != seq {
+		return time.Time{}, false
+	}
+	return time.Unix(0, e.t), true
+}
+
+// Seq returns the number of the next write, not counting
+// writes in progress, so that all before it may be read
+func (b *Ring) Seq() uint64 {
+	return b.stored.Load()
+}
+
+// SetSeq sets the number of the next write.
+// It must be called before any writes.
+func (b *Ring) SetSeq(seq uint64) {
+	b.base = seq
+	b.head.Store(seq)
+	b.stored.Store(seq)
+}
+
+// Head returns the index of the slot to be written next
 func (b *Ring) Head() uint32 {
-	return b.head.Load()
+	return uint32(b.head.Load() % uint64(b.size))
+}
+
+// Len returns the number of values held
+func (b *Ring) Len() uint32 {
+	return uint32(min(b.stored.Load()-b.base, uint64(b.size)))
 }
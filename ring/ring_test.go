@@ -1,8 +1,13 @@
 package ring
 
 import (
+	"bytes"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestReadWhenEmpty(t *testing.T) {
@@ -20,7 +25,7 @@ func BenchmarkWriteRingBuffer(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		buffer.Write(data)
+		buffer.Write(time.Now(), data)
 	}
 }
 
@@ -30,10 +35,243 @@ func BenchmarkReadRingBuffer(b *testing.B) {
 	buf := NewRing(size)
 	for i := uint32(0); i < size; i++ {
 		data := []byte(fmt.Sprintf("sample data %d", i))
-		buf.Write(data)
+		buf.Write(time.Now(), data)
 	}
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		buf.Read(uint32(i)%size, 512)
 	}
 }
+
+func TestByteRingEvictsOldest(t *testing.T) {
+	r := NewByteRing(100)
+	var written [][]byte
+	for i := 0; i < 1000; i++ {
+		data := bytes.Repeat([]byte{byte(i)}, 1+i%37)
+		r.Write(time.Now(), data)
+		written = append(written, data)
+		// newest entries must be intact, and fit within the arena
+		var total int
+		var j int
+		for d := range r.Read(0, 1000) {
+			require.Equal(t, written[len(written)-1-j], d)
+			total += len(d)
+			j++
+		}
+		require.Equal(t, int(r.Len()), j)
+		require.LessOrEqual(t, total, 100)
+		// oldest held entry is the only one that may not have fit
+		if j < len(written) {
+			require.Greater(t, total+len(written[len(written)-1-j]), 100-37)
+		}
+	}
+}
+
+func TestByteRingReadOffset(t *testing.T) {
+	r := NewByteRing(1024)
+	for i := 0; i < 10; i++ {
+		r.Write(time.Now(), []byte(fmt.Sprintf("%d", i)))
+	}
+	var got []string
+	for d := range r.Read(3, 4) {
+		got = append(got, string(d))
+	}
+	require.Equal(t, []string{"6", "5", "4", "3"}, got)
+	require.Len(t, r.Read(10, 1), 0)
+}
+
+func TestByteRingDropsOversized(t *testing.T) {
+	r := NewByteRing(10)
+	r.Write(time.Now(), []byte("fits"))
+	r.Write(time.Now(), make([]byte, 11))
+	require.Equal(t, uint32(0), r.Len())
+	require.Equal(t, uint64(2), r.Seq())
+	r.Write(time.Now(), []byte("fits"))
+	require.Equal(t, uint32(1), r.Len())
+}
+
+func TestGet(t *testing.T) {
+	rings := map[string]interface {
+		Write(time.Time, []byte)
+		Get(uint64) ([]byte, bool)
+		Time(uint64) (time.Time, bool)
+		SetSeq(uint64)
+	}{
+		"Ring":     NewRing(4),
+		"ByteRing": NewByteRing(4),
+	}
+	for name, r := range rings {
+		r.SetSeq(100)
+		for i := 0; i < 6; i++ {
+			r.Write(time.Unix(int64(i), 0), []byte(fmt.Sprintf("%d", i)))
+		}
+		for seq := uint64(102); seq < 106; seq++ {
+			d, ok := r.Get(seq)
+			require.True(t, ok, name)
+			require.Equal(t, fmt.Sprintf("%d", seq-100), string(d), name)
+			wt, ok := r.Time(seq)
+			require.True(t, ok, name)
+			require.Equal(t, int64(seq-100), wt.Unix(), name)
+		}
+		_, ok := r.Get(101)
+		require.False(t, ok, name)
+		_, ok = r.Get(106)
+		require.False(t, ok, name)
+		_, ok = r.Time(106)
+		require.False(t, ok, name)
+	}
+}
+
+func BenchmarkWriteByteRing(b *testing.B) {
+	r := NewByteRing(1 << 20)
+	data := []byte("sample data")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Write(time.Now(), data)
+	}
+}
+
+func TestReadOffset(t *testing.T) {
+	r := NewRing(5)
+	for i := 0; i < 8; i++ {
+		r.Write(time.Now(), []byte(fmt.Sprintf("%d", i)))
+	}
+	var got []string
+	for d := range r.Read(1, 10) {
+		got = append(got, string(d))
+	}
+	require.Equal(t, []string{"6", "5", "4", "3"}, got)
+	require.Equal(t, uint32(3), r.Head())
+	require.Equal(t, uint32(5), r.Len())
+}
+
+// TestConcurrentWriters is most useful with -race
+func TestConcurrentWriters(t *testing.T) {
+	const writers, writes = 8, 1000
+	r := NewRing(512)
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	go func() {
+		// readers must only see complete values
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			for d := range r.Read(0, 512) {
+				if len(d) != 8 {
+					t.Errorf("read incomplete value %q", d)
+				}
+			}
+		}
+	}()
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				r.Write(time.Now(), []byte(fmt.Sprintf("%02d:%05d", w, i)))
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(done)
+	require.Equal(t, uint32(512), r.Len())
+	// every slot holds one of the last writes
+	seen := make(map[string]bool)
+	for d := range r.Read(0, 512) {
+		require.False(t, seen[string(d)], "duplicate %s", d)
+		seen[string(d)] = true
+	}
+	require.Len(t, seen, 512)
+}
+
+func TestSeqCountsStoredWrites(t *testing.T) {
+	r := NewRing(1024)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20000; i++ {
+			r.Write(time.Now(), []byte("test"))
+		}
+	}()
+	for i := 0; i < 20000; i++ {
+		n := r.Seq()
+		if n == 0 {
+			continue
+		}
+		_, ok := r.Get(n - 1)
+		require.True(t, ok, "write %d counted before stored", n-1)
+	}
+	<-done
+	require.Equal(t, uint64(20000), r.Seq())
+}
+
+func TestConcurrentByteRing(t *testing.T) {
+	r := NewByteRing(4096)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				r.Write(time.Now(), []byte("sample data"))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				for d := range r.Read(0, 100) {
+					if string(d) != "sample data" {
+						t.Errorf("read corrupt value %q", d)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkWriteRingParallel(b *testing.B) {
+	r := NewRing(1024)
+	data := []byte("sample data")
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			r.Write(time.Now(), data)
+		}
+	})
+}
+
+func BenchmarkReadWriteRingParallel(b *testing.B) {
+	r := NewRing(4096)
+	data := []byte("sample data")
+	for i := 0; i < 4096; i++ {
+		r.Write(time.Now(), data)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if i%8 == 0 {
+				for range r.Read(0, 64) {
+				}
+			} else {
+				r.Write(time.Now(), data)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkWriteByteRingParallel(b *testing.B) {
+	r := NewByteRing(1 << 20)
+	data := []byte("sample data")
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			r.Write(time.Now(), data)
+		}
+	})
+}
@@ -0,0 +1,410 @@
+package segment
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dataExt = ".seg"
+	idxExt  = ".idx"
+	// record header: crc32 of time & data, data length, unix nano time
+	headerLen = 4 + 4 + 8
+	// index entry: record offset in segment, unix nano time
+	idxEntryLen = 8 + 8
+)
+
+var ErrNotFound = errors.New("record not found")
+
+// Log is an append-only list of records, split into
+// segment files that are rotated when they reach maxSize.
+// Records are numbered from 0, in order of writing.
+type Log struct {
+	mu       sync.RWMutex
+	dir      string
+	maxSize  int64
+	segments []*segment
+}
+
+// segment is one pair of data & index files.
+// The index holds the offset and time of every record.
+type segment struct {
+	base uint64 // number of the first record
+	n    uint64 // number of records
+	size int64  // size of data file
+	data *os.File
+	idx  *os.File
+}
+
+// Open opens or creates the log in dir. The tail of the
+// last segment is verified, and any torn record truncated.
+func Open(dir string, maxSize int64) (*Log, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("err making dir: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("err reading dir: %w", err)
+	}
+	bases := make([]uint64, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, dataExt) {
+			continue
+		}
+		base, err := strconv.ParseUint(strings.TrimSuffix(name, dataExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		bases = append(bases, base)
+	}
+	sort.Slice(bases, func(i, j int) bool { return bases[i] < bases[j] })
+	l := &Log{
+		dir:      dir,
+		maxSize:  maxSize,
+		segments: make([]*segment, 0, len(bases)+1),
+	}
+	for i, base := range bases {
+		seg, err := l.openSegment(base, i == len(bases)-1)
+		if err != nil {
+			l.Close()
+			return nil, fmt.Errorf("err opening segment %d: %w", base, err)
+		}
+		l.segments = append(l.segments, seg)
+	}
+	if len(l.segments) == 0 {
+		seg, err := l.openSegment(0, true)
+		if err != nil {
+			return nil, fmt.Errorf("err creating segment: %w", err)
+		}
+		l.segments = append(l.segments, seg)
+	}
+	return l, nil
+}
+
+func (l *Log) openSegment(base uint64, last bool) (*segment, error) {
+	name := filepath.Join(l.dir, fmt.Sprintf("%020d", base))
+	data, err := os.OpenFile(name+dataExt, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := os.OpenFile(name+idxExt, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	seg := &segment{base: base, data: data, idx: idx}
+	err = seg.load(last)
+	if err != nil {
+		seg.close()
+		return nil, err
+	}
+	return seg, nil
+}
+
+// load reads the sizes of the segment files. If verify is set,
+// records written after the last index entry are validated
+// and indexed, and anything after the last good record is cut.
+func (seg *segment) load(verify bool) error {
+	dataInfo, err := seg.data.Stat()
+	if err != nil {
+		return err
+	}
+	idxInfo, err := seg.idx.Stat()
+	if err != nil {
+		return err
+	}
+	seg.size = dataInfo.Size()
+	seg.n = uint64(idxInfo.Size() / idxEntryLen)
+	if !verify {
+		return nil
+	}
+	// drop index entries pointing beyond the data
+	var end int64
+	for seg.n > 0 {
+		off, _, err := seg.readIdx(seg.n - 1)
+		if err != nil {
+			return err
+		}
+		rec, _, err := seg.readRecordAt(off)
+		if err == nil {
+			end = off + headerLen + int64(len(rec))
+			break
+		}
+		seg.n--
+	}
+	// index any complete records missing from the index
+	for end < seg.size {
+		rec, t, err := seg.readRecordAt(end)
+		if err != nil {
+			break
+		}
+		err = seg.writeIdx(seg.n, end, t)
+		if err != nil {
+			return err
+		}
+		seg.n++
+		end += headerLen + int64(len(rec))
+	}
+	seg.size = end
+	err = seg.data.Truncate(end)
+	if err != nil {
+		return err
+	}
+	return seg.idx.Truncate(int64(seg.n) * idxEntryLen)
+}
+
+func (seg *segment) readIdx(i uint64) (int64, int64, error) {
+	buf := make([]byte, idxEntryLen)
+	_, err := seg.idx.ReadAt(buf, int64(i)*idxEntryLen)
+	if err != nil {
+		return 0, 0, err
+	}
+	off := int64(binary.BigEndian.Uint64(buf))
+	t := int64(binary.BigEndian.Uint64(buf[8:]))
+	return off, t, nil
+}
+
+func (seg *segment) writeIdx(i uint64, off, t int64) error {
+	buf := make([]byte, idxEntryLen)
+	binary.BigEndian.PutUint64(buf, uint64(off))
+	binary.BigEndian.PutUint64(buf[8:], uint64(t))
+	_, err := seg.idx.WriteAt(buf, int64(i)*idxEntryLen)
+	return err
+}
+
+// readRecordAt reads and verifies the record at off
+func (seg *segment) readRecordAt(off int64) ([]byte, int64, error) {
+	header := make([]byte, headerLen)
+	_, err := seg.data.ReadAt(header, off)
+	if err != nil {
+		return nil, 0, err
+	}
+	sum := binary.BigEndian.Uint32(header)
+	length := int64(binary.BigEndian.Uint32(header[4:]))
+	if off+headerLen+length > seg.size {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	buf := make([]byte, 8+length)
+	copy(buf, header[8:])
+	_, err = seg.data.ReadAt(buf[8:], off+headerLen)
+	if err != nil {
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(buf) != sum {
+		return nil, 0, errors.New("checksum mismatch")
+	}
+	return buf[8:], int64(binary.BigEndian.Uint64(buf)), nil
+}
+
+func (seg *segment) sync() error {
+	return errors.Join(seg.data.Sync(), seg.idx.Sync())
+}
+
+func (seg *segment) close() error {
+	return errors.Join(seg.data.Close(), seg.idx.Close())
+}
+
+func (seg *segment) remove() error {
+	return errors.Join(
+		os.Remove(seg.data.Name()),
+		os.Remove(seg.idx.Name()))
+}
+
+// Append writes a record to the end of the log,
+// rotating the active segment if it would grow beyond maxSize.
+func (l *Log) Append(t time.Time, data []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	seg := l.segments[len(l.segments)-1]
+	recLen := int64(headerLen + len(data))
+	if seg.n > 0 && seg.size+recLen > l.maxSize {
+		err := l.rotate()
+		if err != nil {
+			return fmt.Errorf("err rotating segment: %w", err)
+		}
+		seg = l.segments[len(l.segments)-1]
+	}
+	buf := make([]byte, recLen)
+	binary.BigEndian.PutUint32(buf[4:], uint32(len(data)))
+	binary.BigEndian.PutUint64(buf[8:], uint64(t.UnixNano()))
+	copy(buf[headerLen:], data)
+	binary.BigEndian.PutUint32(buf, crc32.ChecksumIEEE(buf[8:]))
+	_, err := seg.data.WriteAt(buf, seg.size)
+	if err != nil {
+		return fmt.Errorf("err writing record: %w", err)
+	}
+	err = seg.writeIdx(seg.n, seg.size, t.UnixNano())
+	if err != nil {
+		return fmt.Errorf("err writing index: %w", err)
+	}
+	seg.size += recLen
+	seg.n++
+	return nil
+}
+
+// rotate starts a new segment
+func (l *Log) rotate() error {
+	last := l.segments[len(l.segments)-1]
+	err := last.sync()
+	if err != nil {
+		return err
+	}
+	seg, err := l.openSegment(last.base+last.n, true)
+	if err != nil {
+		return err
+	}
+	l.segments = append(l.segments, seg)
+	return nil
+}
+
+// Trim removes the oldest segments while their last record is
+// before cutoff, or the log is larger than maxSize.
+// Zero values are ignored. The active segment is never removed.
+func (l *Log) Trim(cutoff time.Time, maxSize int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	size := l.size()
+	for len(l.segments) > 1 {
+		oldest := l.segments[0]
+		_, t, err := oldest.readIdx(oldest.n - 1)
+		if err != nil {
+			return fmt.Errorf("err reading index: %w", err)
+		}
+		expired := !cutoff.IsZero() && t < cutoff.UnixNano()
+		tooBig := maxSize > 0 && size > maxSize
+		if !expired && !tooBig {
+			return nil
+		}
+		oldest.close()
+		err = oldest.remove()
+		if err != nil {
+			return fmt.Errorf("err removing segment %d: %w", oldest.base, err)
+		}
+		size -= oldest.size
+		l.segments = l.segments[1:]
+	}
+	return nil
+}
+
+// Size returns the total size of the segment data files
+func (l *Log) Size() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.size()
+}
+
+func (l *Log) size() int64 {
+	var size int64
+	for _, seg := range l.segments {
+		size += seg.size
+	}
+	return size
+}
+
+// Search returns the number of the first record written at or
+// after t, or Count if there is none
+func (l *Log) Search(t time.Time) (uint64, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	tn := t.UnixNano()
+	var err error
+	// find first segment with a last record at or after t
+	s := sort.Search(len(l.segments), func(j int) bool {
+		seg := l.segments[j]
+		if seg.n == 0 {
+			return true
+		}
+		_, last, e := seg.readIdx(seg.n - 1)
+		if e != nil {
+			err = e
+		}
+		return last >= tn
+	})
+	if err != nil {
+		return 0, fmt.Errorf("err reading index: %w", err)
+	}
+	if s == len(l.segments) {
+		last := l.segments[s-1]
+		return last.base + last.n, nil
+	}
+	seg := l.segments[s]
+	i := sort.Search(int(seg.n), func(j int) bool {
+		_, rt, e := seg.readIdx(uint64(j))
+		if e != nil {
+			err = e
+		}
+		return rt >= tn
+	})
+	if err != nil {
+		return 0, fmt.Errorf("err reading index: %w", err)
+	}
+	return seg.base + uint64(i), nil
+}
+
+// First returns the number of the oldest record
+func (l *Log) First() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.segments[0].base
+}
+
+// Count returns the number of records ever written,
+// which is also the number of the next record
+func (l *Log) Count() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	last := l.segments[len(l.segments)-1]
+	return last.base + last.n
+}
+
+// Read returns the data & time of record number i
+func (l *Log) Read(i uint64) ([]byte, time.Time, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	s := sort.Search(len(l.segments), func(j int) bool {
+		return l.segments[j].base > i
+	}) - 1
+	if s < 0 || i >= l.segments[s].base+l.segments[s].n {
+		return nil, time.Time{}, ErrNotFound
+	}
+	seg := l.segments[s]
+	off, _, err := seg.readIdx(i - seg.base)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("err reading index: %w", err)
+	}
+	data, t, err := seg.readRecordAt(off)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("err reading record: %w", err)
+	}
+	return data, time.Unix(0, t), nil
+}
+
+// Sync flushes the active segment to disk.
+// Rotated segments are synced on rotation.
+func (l *Log) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.segments[len(l.segments)-1].sync()
+}
+
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var errs []error
+	for _, seg := range l.segments {
+		errs = append(errs, seg.close())
+	}
+	return errors.Join(errs...)
+}
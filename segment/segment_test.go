@@ -0,0 +1,111 @@
+package segment
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendRead(t *testing.T) {
+	l, err := Open(t.TempDir(), 1024)
+	require.NoError(t, err)
+	defer l.Close()
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		err := l.Append(now.Add(time.Duration(i)), []byte(fmt.Sprintf("record %d", i)))
+		require.NoError(t, err)
+	}
+	require.Equal(t, uint64(100), l.Count())
+	require.Greater(t, len(l.segments), 1, "expected segments to be rotated")
+	for i := uint64(0); i < 100; i++ {
+		data, tm, err := l.Read(i)
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("record %d", i), string(data))
+		require.Equal(t, now.Add(time.Duration(i)).UnixNano(), tm.UnixNano())
+	}
+	_, _, err = l.Read(100)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, 1024)
+	require.NoError(t, err)
+	for i := 0; i < 50; i++ {
+		require.NoError(t, l.Append(time.Now(), []byte("test")))
+	}
+	require.NoError(t, l.Close())
+	l, err = Open(dir, 1024)
+	require.NoError(t, err)
+	defer l.Close()
+	require.Equal(t, uint64(50), l.Count())
+	require.NoError(t, l.Append(time.Now(), []byte("after reopen")))
+	data, _, err := l.Read(50)
+	require.NoError(t, err)
+	require.Equal(t, "after reopen", string(data))
+}
+
+func TestTornTail(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, 1<<20)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, l.Append(time.Now(), []byte("test")))
+	}
+	seg := l.segments[0]
+	dataName := seg.data.Name()
+	require.NoError(t, l.Close())
+	// simulate a crash part way through writing the last record
+	info, err := os.Stat(dataName)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(dataName, info.Size()-2))
+	l, err = Open(dir, 1<<20)
+	require.NoError(t, err)
+	defer l.Close()
+	require.Equal(t, uint64(9), l.Count())
+	_, _, err = l.Read(9)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestTrim(t *testing.T) {
+	l, err := Open(t.TempDir(), 64)
+	require.NoError(t, err)
+	defer l.Close()
+	old := time.Now().Add(-2 * time.Hour)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, l.Append(old, []byte("old record")))
+	}
+	for i := 0; i < 10; i++ {
+		require.NoError(t, l.Append(time.Now(), []byte("new record")))
+	}
+	require.NoError(t, l.Trim(time.Now().Add(-time.Hour), 0))
+	require.Equal(t, uint64(10), l.First())
+	data, _, err := l.Read(l.First())
+	require.NoError(t, err)
+	require.Equal(t, "new record", string(data))
+
+	require.NoError(t, l.Trim(time.Time{}, 64))
+	require.LessOrEqual(t, l.Size(), int64(64))
+	require.Equal(t, uint64(20), l.Count())
+}
+
+func TestSearch(t *testing.T) {
+	l, err := Open(t.TempDir(), 256)
+	require.NoError(t, err)
+	defer l.Close()
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		require.NoError(t, l.Append(start.Add(time.Duration(i)*time.Second), []byte("test")))
+	}
+	for _, i := range []uint64{0, 1, 50, 99} {
+		n, err := l.Search(start.Add(time.Duration(i) * time.Second))
+		require.NoError(t, err)
+		require.Equal(t, i, n)
+	}
+	n, err := l.Search(start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, uint64(100), n)
+}
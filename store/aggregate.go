@@ -0,0 +1,95 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/intob/logd/cmd"
+)
+
+// Aggregation groups the msgs matching a query, counting each group
+type Aggregation struct {
+	ByKey    bool
+	KeyDepth int // segments of key grouped by, all if 0
+	ByLvl    bool
+	Bucket   time.Duration // of msg time, not grouped by time if 0
+	TopKeys  int           // number of keys counted most, by KeyDepth
+}
+
+// Group is the key, lvl & bucket of the msgs counted,
+// each zero if not grouped by
+type Group struct {
+	Key string
+	Lvl cmd.Lvl
+	T   time.Time // start of the bucket
+}
+
+type KeyCount struct {
+	Key string
+	N   uint64
+}
+
+// Aggregate is the result of an aggregation
+type Aggregate struct {
+	Counts    map[Group]uint64
+	TopKeys   []KeyCount // most first
+	Matched   uint64
+	Truncated bool // at the limit of the query
+}
+
+// Aggregate counts the msgs read by q, which must be limited
+func (s *Store) Aggregate(q *Query, a *Aggregation) *Aggregate {
+	agg := &Aggregate{Counts: make(map[Group]uint64)}
+	keys := make(map[string]uint64)
+	for e := range s.Read(q).Entries {
+		agg.Matched++
+		key := keyAtDepth(e.Msg.GetKey(), a.KeyDepth)
+		if a.TopKeys > 0 {
+			keys[key]++
+		}
+		g := Group{}
+		if a.ByKey {
+			g.Key = key
+		}
+		if a.ByLvl {
+			g.Lvl = e.Msg.GetLvl()
+		}
+		if a.Bucket > 0 {
+			g.T = e.Msg.GetT().AsTime().Truncate(a.Bucket)
+		}
+		agg.Counts[g]++
+	}
+	agg.Truncated = agg.Matched == uint64(q.Limit)
+	for key, n := range keys {
+		agg.TopKeys = append(agg.TopKeys, KeyCount{key, n})
+	}
+	slices.SortFunc(agg.TopKeys, func(a, b KeyCount) int {
+		if a.N != b.N {
+			return cmp.Compare(b.N, a.N)
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	if len(agg.TopKeys) > a.TopKeys {
+		agg.TopKeys = agg.TopKeys[:a.TopKeys]
+	}
+	return agg
+}
+
+// keyAtDepth returns the first depth segments of key,
+// or key if depth is 0 or key has no more segments
+func keyAtDepth(key string, depth int) string {
+	if depth <= 0 {
+		return key
+	}
+	i := 0
+	for n := 0; n <= depth; n++ {
+		j := strings.IndexByte(key[i:], '/')
+		if j < 0 {
+			return key
+		}
+		i += j + 1
+	}
+	return key[:i-1]
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestAggregate(t *testing.T) {
+	s, err := NewStore(&Cfg{FallbackSize: 100})
+	require.NoError(t, err)
+	defer s.Close()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	write := func(key string, lvl cmd.Lvl, offset time.Duration) {
+		msgT := start.Add(offset)
+		data, err := proto.Marshal(&cmd.Msg{Key: key, Lvl: lvl, T: timestamppb.New(msgT)})
+		require.NoError(t, err)
+		require.NoError(t, s.Write(key, msgT, data))
+	}
+	write("/prod/api/a", cmd.Lvl_INFO, 0)
+	write("/prod/api/b", cmd.Lvl_ERROR, 10*time.Second)
+	write("/prod/web", cmd.Lvl_INFO, time.Minute)
+	write("/prod/web", cmd.Lvl_INFO, time.Minute+time.Second)
+	write("/prod/web", cmd.Lvl_WARN, 2*time.Minute)
+
+	agg := s.Aggregate(&Query{Limit: 100}, &Aggregation{
+		ByKey:    true,
+		KeyDepth: 2,
+		Bucket:   time.Minute,
+		TopKeys:  1,
+	})
+	require.Equal(t, uint64(5), agg.Matched)
+	require.False(t, agg.Truncated)
+	require.Equal(t, map[Group]uint64{
+		{Key: "/prod/api", T: start}:                      2,
+		{Key: "/prod/web", T: start.Add(time.Minute)}:     2,
+		{Key: "/prod/web", T: start.Add(2 * time.Minute)}: 1,
+	}, agg.Counts)
+	require.Equal(t, []KeyCount{{"/prod/web", 3}}, agg.TopKeys)
+
+	agg = s.Aggregate(&Query{Limit: 3}, &Aggregation{ByLvl: true})
+	require.True(t, agg.Truncated)
+	require.Equal(t, map[Group]uint64{
+		{Lvl: cmd.Lvl_INFO}: 2,
+		{Lvl: cmd.Lvl_WARN}: 1,
+	}, agg.Counts)
+}
+
+func TestKeyAtDepth(t *testing.T) {
+	require.Equal(t, "/a", keyAtDepth("/a/b/c", 1))
+	require.Equal(t, "/a/b", keyAtDepth("/a/b/c", 2))
+	require.Equal(t, "/a/b/c", keyAtDepth("/a/b/c", 3))
+	require.Equal(t, "/a/b/c", keyAtDepth("/a/b/c", 0))
+	require.Equal(t, "/a", keyAtDepth("/a", 5))
+}
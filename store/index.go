@@ -0,0 +1,174 @@
+package store
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/intob/logd/cmd"
+	"google.golang.org/protobuf/proto"
+)
+
+// index maps the tokens of msg txt to the seqs of the records in the
+// ring that contain them. Records are evicted as the ring overwrites them.
+type index struct {
+	mu       sync.RWMutex        // also orders writes to the ring
+	postings map[string][]uint64 // seqs by token, ascending
+	docs     []doc               // indexed records, oldest first
+	first    uint64              // records from first are indexed
+}
+
+type doc struct {
+	seq    uint64
+	tokens []string
+}
+
+// hits are the seqs of records that may contain a txt,
+// of those from the first seq indexed
+type hits struct {
+	seqs []uint64
+	from uint64
+}
+
+func newIndex(first uint64) *index {
+	return &index{
+		postings: make(map[string][]uint64),
+		first:    first,
+	}
+}
+
+// tokenize splits txt into lowercase runs of letters and digits
+func tokenize(txt string) []string {
+	return strings.FieldsFunc(strings.ToLower(txt), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func (x *index) add(seq uint64, data []byte) {
+	msg := &cmd.Msg{}
+	// records that are not msgs have no tokens
+	_ = proto.Unmarshal(data, msg)
+	tokens := tokenize(msg.GetTxt())
+	slices.Sort(tokens)
+	tokens = slices.Compact(tokens)
+	for _, t := range tokens {
+		x.postings[t] = append(x.postings[t], seq)
+	}
+	x.docs = append(x.docs, doc{seq: seq, tokens: tokens})
+}
+
+// evict removes the records before seq
+func (x *index) evict(seq uint64) {
+	for len(x.docs) > 0 && x.docs[0].seq < seq {
+		for _, t := range x.docs[0].tokens {
+			if len(x.postings[t]) == 1 {
+				delete(x.postings, t)
+				continue
+			}
+			x.postings[t] = x.postings[t][1:]
+		}
+		x.docs = x.docs[1:]
+	}
+	x.first = max(x.first, seq)
+}
+
+// lookup returns the seqs of records that may contain txt as a
+// substring, ignoring case, or nil if txt has no tokens. Tokens within
+// txt must match whole, but the first and last may be cut.
+func (x *index) lookup(txt string) []uint64 {
+	tokens := tokenize(txt)
+	if len(tokens) == 0 {
+		return nil
+	}
+	runes := []rune(txt)
+	cutStart := isTokenRune(runes[0])
+	cutEnd := isTokenRune(runes[len(runes)-1])
+	var seqs []uint64
+	for i, t := range tokens {
+		var found []uint64
+		switch {
+		case len(tokens) == 1 && cutStart && cutEnd:
+			found = x.scan(func(s string) bool { return strings.Contains(s, t) })
+		case i == 0 && cutStart:
+			found = x.scan(func(s string) bool { return strings.HasSuffix(s, t) })
+		case i == len(tokens)-1 && cutEnd:
+			found = x.scan(func(s string) bool { return strings.HasPrefix(s, t) })
+		default:
+			found = slices.Clone(x.postings[t])
+		}
+		if i == 0 {
+			seqs = found
+		} else {
+			seqs = intersect(seqs, found)
+		}
+		if len(seqs) == 0 {
+			return []uint64{}
+		}
+	}
+	return seqs
+}
+
+// scan returns the seqs of records with any token satisfying fn
+func (x *index) scan(fn func(string) bool) []uint64 {
+	var seqs []uint64
+	for t, p := range x.postings {
+		if fn(t) {
+			seqs = append(seqs, p...)
+		}
+	}
+	slices.Sort(seqs)
+	return slices.Compact(seqs)
+}
+
+func isTokenRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// intersect returns the seqs in both a and b, which must be ascending
+func intersect(a, b []uint64) []uint64 {
+	out := a[:0]
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// push writes data to the ring, indexing it if the part is indexed
+func (p *part) push(t time.Time, data []byte) {
+	if p.index == nil {
+		p.ring.Write(t, data)
+		return
+	}
+	p.index.mu.Lock()
+	defer p.index.mu.Unlock()
+	seq := p.ring.Seq()
+	p.ring.Write(t, data)
+	p.index.add(seq, data)
+	p.index.evict(seq + 1 - uint64(p.ring.Len()))
+}
+
+// pin returns the seq of the next write, and the hits for txt
+// if the part is indexed, so that both agree
+func (p *part) pin(txt string) (uint64, *hits) {
+	if p.index == nil || txt == "" {
+		return p.ring.Seq(), nil
+	}
+	p.index.mu.RLock()
+	defer p.index.mu.RUnlock()
+	seqs := p.index.lookup(txt)
+	if seqs == nil {
+		return p.ring.Seq(), nil
+	}
+	return p.ring.Seq(), &hits{seqs: seqs, from: p.index.first}
+}
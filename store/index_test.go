@@ -0,0 +1,90 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestIndexLookup(t *testing.T) {
+	x := newIndex(0)
+	txts := []string{
+		"read tcp: Connection reset by peer",
+		"connection refused",
+		"reset password for user 42",
+		"peer reset connection",
+	}
+	for i, txt := range txts {
+		data, err := proto.Marshal(&cmd.Msg{Txt: txt})
+		require.NoError(t, err)
+		x.add(uint64(i), data)
+	}
+	cases := map[string][]uint64{
+		"connection reset": {0, 3}, // a superset, Match checks order
+		"CONNECTION":       {0, 1, 3},
+		"nection re":       {0, 1, 3},
+		"onnec":            {0, 1, 3},
+		"user 4":           {2},
+		" reset ":          {0, 2, 3},
+		" rese ":           {},
+		"nothing":          {},
+	}
+	for txt, want := range cases {
+		require.Equal(t, want, x.lookup(txt), txt)
+	}
+	require.Nil(t, x.lookup(" :: "))
+	x.evict(2)
+	require.Equal(t, []uint64{3}, x.lookup("connection"))
+	require.Equal(t, uint64(2), x.first)
+	_, ok := x.postings["refused"]
+	require.False(t, ok)
+}
+
+func TestIndexedRead(t *testing.T) {
+	s, err := NewStore(&Cfg{
+		RingSizes:    map[string]uint32{"/test/a": 20, "/test/b": 20},
+		FallbackSize: 10,
+		DataDir:      t.TempDir(),
+		Index:        []string{"/test/a"},
+	})
+	require.NoError(t, err)
+	defer s.Close()
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		key := "/test/a"
+		if i%3 == 0 {
+			key = "/test/b"
+		}
+		txt := fmt.Sprintf("msg %d", i)
+		if i%5 == 0 {
+			txt += " connection reset"
+		}
+		msgT := start.Add(time.Duration(i) * time.Millisecond)
+		data, err := proto.Marshal(&cmd.Msg{T: timestamppb.New(msgT), Txt: txt})
+		require.NoError(t, err)
+		require.NoError(t, s.Write(key, msgT, data))
+	}
+	match := func(m *cmd.Msg) bool {
+		return strings.Contains(m.GetTxt(), "connection reset")
+	}
+	read := func(q *Query) []string {
+		out := make([]string, 0)
+		for e := range s.Read(q).Entries {
+			out = append(out, e.Msg.GetTxt())
+		}
+		return out
+	}
+	// beyond the ring, records are scanned from disk
+	for _, oldestFirst := range []bool{false, true} {
+		want := read(&Query{KeyPrefix: "/test", Limit: 100, OldestFirst: oldestFirst, Match: match})
+		require.Len(t, want, 20)
+		got := read(&Query{KeyPrefix: "/test", Limit: 100, OldestFirst: oldestFirst, Match: match, Txt: "connection reset"})
+		require.Equal(t, want, got)
+	}
+}
@@ -0,0 +1,267 @@
+package store
+
+import (
+	"container/heap"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"google.golang.org/protobuf/proto"
+)
+
+// Query selects records from all rings with keys having
+// KeyPrefix, or from the fallback ring if none match
+type Query struct {
+	KeyPrefix   string
+	Offset      uint32
+	Limit       uint32
+	OldestFirst bool
+	Cursor      Cursor              // continue from the end of a previous read
+	Since       time.Time           // oldest first, start rings not in Cursor from here
+	Until       time.Time           // oldest first, stop at the first msg after
+	End         Cursor              // if set, stop each ring here, rather than at its head
+	Match       func(*cmd.Msg) bool // only matching msgs count towards offset & limit
+	// Txt is a substring that Match requires. It narrows the read of indexed
+	// rings, but only of records still in the ring; older records on disk,
+	// and txt regexes, are scanned in full. A first or last token of Txt that
+	// may be cut is matched against every token indexed, so a Txt of only
+	// whole words, e.g. " timeout ", narrows best.
+	Txt string
+}
+
+// Entry is a record read from a ring
+type Entry struct {
+	Ring string
+	Seq  uint64
+	Data []byte
+	Msg  *cmd.Msg
+}
+
+// Result is returned by Read. Entries of all rings are merged in order
+// of msg time, and the channel is closed once limit is reached.
+type Result struct {
+	Entries <-chan *Entry
+	cursor  Cursor
+}
+
+// Cursor holds, for each ring, the seq bounding the records that remain
+// to be read. Newest first, they are below it. Oldest first, they are
+// at or above it.
+type Cursor map[string]uint64
+
+// Cursor returns the position after the entries sent.
+// It must only be called once Entries is closed.
+func (r *Result) Cursor() Cursor {
+	return r.cursor
+}
+
+// Drain reads the entries left, so that the read ends.
+// A reader that stops early must call it.
+func (r *Result) Drain() {
+	for range r.Entries {
+	}
+}
+
+// ParseCursor decodes a cursor from query params
+func ParseCursor(data []byte) (Cursor, error) {
+	c := &cmd.Cursor{}
+	err := proto.Unmarshal(data, c)
+	if err != nil {
+		return nil, fmt.Errorf("err unmarshaling cursor: %w", err)
+	}
+	return c.Seqs, nil
+}
+
+// Bytes encodes the cursor, to be returned with the results
+func (c Cursor) Bytes() []byte {
+	data, _ := proto.Marshal(&cmd.Cursor{Seqs: c})
+	return data
+}
+
+// Read reads up to limit entries from offset, merging the rings in
+// order of msg time. Records no longer in a ring are read from disk.
+func (s *Store) Read(q *Query) *Result {
+	out := make(chan *Entry, 1)
+	parts := s.match(q.KeyPrefix)
+	res := &Result{Entries: out, cursor: make(Cursor, len(parts))}
+	streams := &merge{oldestFirst: q.OldestFirst}
+	var err error
+	for _, p := range parts {
+		st := &stream{p: p, oldestFirst: q.OldestFirst}
+		// pin each ring at its head, so pages are not shifted by new writes
+		st.end, st.hits = p.pin(q.Txt)
+		if q.End != nil {
+			st.end = min(st.end, q.End[p.key]) // rings not in End are skipped
+		}
+		pos, ok := q.Cursor[p.key]
+		switch {
+		case ok:
+			st.pos = pos
+		case q.OldestFirst && !q.Since.IsZero():
+			st.pos, err = p.search(q.Since)
+			if err != nil {
+				st.pos = p.oldest()
+			}
+		case q.OldestFirst:
+			st.pos = p.oldest()
+		default:
+			st.pos = st.end
+		}
+		res.cursor[p.key] = st.pos
+		if st.next() {
+			streams.streams = append(streams.streams, st)
+		}
+	}
+	heap.Init(streams)
+	go func() {
+		defer close(out)
+		skip := q.Offset
+		var count uint32
+		for count < q.Limit && streams.Len() > 0 {
+			st := streams.streams[0]
+			e := st.head
+			if q.OldestFirst && !q.Until.IsZero() && e.Msg.GetT().AsTime().After(q.Until) {
+				break // as merged in order of msg time, so are those left
+			}
+			res.cursor[e.Ring] = st.pos
+			if st.next() {
+				heap.Fix(streams, 0)
+			} else {
+				heap.Pop(streams)
+			}
+			if q.Match != nil && !q.Match(e.Msg) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			out <- e
+			count++
+		}
+	}()
+	return res
+}
+
+// stream reads one part in order of seq
+type stream struct {
+	p           *part
+	oldestFirst bool
+	pos         uint64 // position after head, as in Cursor
+	end         uint64 // seq of next write when the read began
+	hits        *hits  // nil if not indexed
+	head        *Entry
+}
+
+// next reads the next entry into head, returning false when done
+func (st *stream) next() bool {
+	oldest := st.p.oldest()
+	var seq uint64
+	if st.oldestFirst {
+		st.pos = max(st.pos, oldest)
+		st.skipUp()
+		if st.pos >= st.end {
+			return false
+		}
+		seq = st.pos
+		st.pos++
+	} else {
+		st.skipDown()
+		if st.pos <= oldest {
+			return false
+		}
+		st.pos--
+		seq = st.pos
+	}
+	data, err := st.p.get(seq)
+	if err != nil {
+		fmt.Printf("err reading %s record %d: %v\n", st.p.key, seq, err)
+		return false
+	}
+	msg := &cmd.Msg{}
+	// records that are not msgs are ordered as the zero time
+	_ = proto.Unmarshal(data, msg)
+	st.head = &Entry{Ring: st.p.key, Seq: seq, Data: data, Msg: msg}
+	return true
+}
+
+// skipUp moves pos to the next hit, if indexed from pos
+func (st *stream) skipUp() {
+	if st.hits == nil || st.pos < st.hits.from {
+		return
+	}
+	i, _ := slices.BinarySearch(st.hits.seqs, st.pos)
+	if i < len(st.hits.seqs) {
+		st.pos = st.hits.seqs[i]
+		return
+	}
+	st.pos = st.end
+}
+
+// skipDown moves pos to after the previous hit, if indexed before pos.
+// Records before the first seq indexed are scanned.
+func (st *stream) skipDown() {
+	if st.hits == nil || st.pos <= st.hits.from {
+		return
+	}
+	i, _ := slices.BinarySearch(st.hits.seqs, st.pos)
+	if i > 0 && st.hits.seqs[i-1] >= st.hits.from {
+		st.pos = st.hits.seqs[i-1] + 1
+		return
+	}
+	st.pos = st.hits.from
+}
+
+// merge is a heap of streams, ordered by the time of their head
+type merge struct {
+	streams     []*stream
+	oldestFirst bool
+}
+
+func (m *merge) Len() int { return len(m.streams) }
+
+func (m *merge) Less(i, j int) bool {
+	a, b := m.streams[i].head, m.streams[j].head
+	ta, tb := a.Msg.GetT().AsTime(), b.Msg.GetT().AsTime()
+	if !ta.Equal(tb) {
+		return ta.Before(tb) == m.oldestFirst
+	}
+	if a.Ring != b.Ring {
+		return a.Ring < b.Ring
+	}
+	return (a.Seq < b.Seq) == m.oldestFirst
+}
+
+func (m *merge) Swap(i, j int) { m.streams[i], m.streams[j] = m.streams[j], m.streams[i] }
+
+func (m *merge) Push(x any) { m.streams = append(m.streams, x.(*stream)) }
+
+func (m *merge) Pop() any {
+	last := m.streams[len(m.streams)-1]
+	m.streams = m.streams[:len(m.streams)-1]
+	return last
+}
+
+// match returns the part with key, or all parts with the key prefix,
+// in order of key, or the fallback part if none match
+func (s *Store) match(keyPrefix string) []*part {
+	if p, ok := s.rings[keyPrefix]; ok {
+		return []*part{p}
+	}
+	parts := make([]*part, 0)
+	for key, p := range s.rings {
+		if strings.HasPrefix(key, keyPrefix) {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return []*part{s.fallback}
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].key < parts[j].key
+	})
+	return parts
+}
@@ -0,0 +1,193 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultCompactEvery = time.Minute
+
+// Retention limits how long, and how much, is kept of a ring.
+// MaxAge applies to the ring and the log on disk,
+// MaxBytes applies to the log on disk.
+type Retention struct {
+	MaxAge   time.Duration `yaml:"max_age"`
+	MaxBytes ByteSize      `yaml:"max_bytes"`
+}
+
+// ByteSize is a number of bytes, that may be given
+// in yaml with a unit, such as 512MB or 2GiB
+type ByteSize int64
+
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"B", 1},
+}
+
+func (b *ByteSize) UnmarshalYAML(value *yaml.Node) error {
+	s := strings.TrimSpace(value.Value)
+	mult := int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, u.suffix))
+			mult = u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid byte size %q", value.Value)
+	}
+	*b = ByteSize(n * float64(mult))
+	return nil
+}
+
+// Window is the time span held by a ring
+type Window struct {
+	Oldest, Newest time.Time
+	DiskBytes      int64
+	Retention      *Retention
+}
+
+// compactEvery enforces retention periodically, until quit
+func (s *Store) compactEvery(period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-ticker.C:
+			s.compact()
+		}
+	}
+}
+
+func (s *Store) compact() {
+	for _, p := range s.parts() {
+		err := p.compact(time.Now())
+		if err != nil {
+			fmt.Printf("err compacting %s: %v\n", p.key, err)
+		}
+	}
+}
+
+// compact removes expired segments from disk, and raises
+// the floor of the ring past records older than max age
+func (p *part) compact(now time.Time) error {
+	if p.retention == nil {
+		return nil
+	}
+	var cutoff time.Time
+	if p.retention.MaxAge > 0 {
+		cutoff = now.Add(-p.retention.MaxAge)
+	}
+	if p.log != nil {
+		err := p.log.Trim(cutoff, int64(p.retention.MaxBytes))
+		if err != nil {
+			return err
+		}
+	}
+	if cutoff.IsZero() {
+		return nil
+	}
+	floor, err := p.search(cutoff)
+	if err != nil {
+		return err
+	}
+	if floor > p.floor.Load() {
+		p.floor.Store(floor)
+	}
+	return nil
+}
+
+// oldest returns the seq of the oldest retained record
+func (p *part) oldest() uint64 {
+	n := p.ring.Seq()
+	first := n - min(n, uint64(p.ring.Len()))
+	if p.log != nil {
+		first = p.log.First()
+	}
+	return max(first, p.floor.Load())
+}
+
+// search returns the seq of the first retained
+// record at or after t, or the next seq if there is none
+func (p *part) search(t time.Time) (uint64, error) {
+	if p.log != nil {
+		return p.log.Search(t)
+	}
+	// binary search the ring
+	lo, hi := p.oldest(), p.ring.Seq()
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		mt, err := p.timeOf(mid)
+		if err != nil {
+			return 0, err
+		}
+		if mt.Before(t) {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
+
+// timeOf returns the time that record seq was written,
+// in order of seq, unlike the time of the msg
+func (p *part) timeOf(seq uint64) (time.Time, error) {
+	if p.log != nil {
+		_, t, err := p.log.Read(seq)
+		return t, err
+	}
+	t, ok := p.ring.Time(seq)
+	if !ok {
+		return time.Time{}, fmt.Errorf("record %d not in ring", seq)
+	}
+	return t, nil
+}
+
+func (p *part) window() (*Window, error) {
+	w := &Window{Retention: p.retention}
+	if p.log != nil {
+		w.DiskBytes = p.log.Size()
+	}
+	n := p.ring.Seq()
+	oldest := p.oldest()
+	if oldest >= n {
+		return w, nil
+	}
+	var err error
+	w.Oldest, err = p.timeOf(oldest)
+	if err != nil {
+		return nil, err
+	}
+	w.Newest, err = p.timeOf(n - 1)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Windows returns the time span held by each ring
+func (s *Store) Windows() map[string]*Window {
+	windows := make(map[string]*Window, len(s.rings)+1)
+	for _, p := range s.parts() {
+		w, err := p.window()
+		if err != nil {
+			fmt.Printf("err getting window of %s: %v\n", p.key, err)
+			continue
+		}
+		windows[p.key] = w
+	}
+	return windows
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"gopkg.in/yaml.v3"
+)
+
+func TestByteSize(t *testing.T) {
+	cases := map[string]ByteSize{
+		"1024":   1024,
+		"2GiB":   2 << 30,
+		"512 MB": 512e6,
+		"1.5KiB": 1536,
+		"100B":   100,
+	}
+	for in, want := range cases {
+		var got ByteSize
+		require.NoError(t, yaml.Unmarshal([]byte(in), &got), in)
+		require.Equal(t, want, got, in)
+	}
+	var b ByteSize
+	require.Error(t, yaml.Unmarshal([]byte("lots"), &b))
+}
+
+func TestMaxAge(t *testing.T) {
+	s, err := NewStore(&Cfg{
+		RingSizes:    map[string]uint32{"/test/app": 100},
+		FallbackSize: 10,
+		Retention: map[string]*Retention{
+			"/test/app": {MaxAge: time.Hour},
+		},
+	})
+	require.NoError(t, err)
+	defer s.Close()
+	now := time.Now()
+	for i := 0; i < 20; i++ {
+		msgT := now.Add(-2 * time.Hour)
+		if i >= 10 {
+			msgT = now
+		}
+		data, err := proto.Marshal(&cmd.Msg{T: timestamppb.New(msgT), Txt: fmt.Sprintf("%d", i)})
+		require.NoError(t, err)
+		require.NoError(t, s.Write("/test/app", msgT, data))
+	}
+	require.Len(t, readAll(s.Read(&Query{KeyPrefix: "/test/app", Offset: 0, Limit: 100})), 20)
+	s.compact()
+	require.Len(t, readAll(s.Read(&Query{KeyPrefix: "/test/app", Offset: 0, Limit: 100})), 10)
+	w := s.Windows()["/test/app"]
+	require.Equal(t, now.UnixNano(), w.Oldest.UnixNano())
+	require.Equal(t, now.UnixNano(), w.Newest.UnixNano())
+}
+
+func TestMaxAgeByTimeWritten(t *testing.T) {
+	for name, dataDir := range map[string]string{"ring": "", "log": t.TempDir()} {
+		s, err := NewStore(&Cfg{
+			RingSizes:    map[string]uint32{"/test/app": 100},
+			FallbackSize: 10,
+			DataDir:      dataDir,
+			Retention: map[string]*Retention{
+				"/test/app": {MaxAge: time.Hour},
+			},
+		})
+		require.NoError(t, err)
+		now := time.Now()
+		for i := 0; i < 10; i++ {
+			msgT := now
+			if i == 5 {
+				msgT = time.Unix(0, 0) // from a client with a bad clock
+			}
+			data, err := proto.Marshal(&cmd.Msg{T: timestamppb.New(msgT), Txt: fmt.Sprintf("%d", i)})
+			require.NoError(t, err)
+			require.NoError(t, s.Write("/test/app", now, data))
+		}
+		s.compact()
+		require.Len(t, readAll(s.Read(&Query{KeyPrefix: "/test/app", Limit: 100})), 10, name)
+		require.NoError(t, s.Close())
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	s, err := NewStore(&Cfg{FallbackSize: 10, DataDir: t.TempDir()})
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+	require.NoError(t, s.Close())
+}
@@ -1,93 +1,410 @@
 package store
 
 import (
+	"errors"
 	"fmt"
-	"strings"
+	"math"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/intob/logd/ring"
+	"github.com/intob/logd/segment"
+	"github.com/intob/logd/wal"
+)
+
+const (
+	fallbackKey        = "_fallback"
+	defaultSegmentSize = 64 << 20
+	walFname           = "wal"
+	walCheckpointSize  = 64 << 20 // sync logs & reset wal after
 )
 
 type Store struct {
-	rings    map[string]*ring.Ring
-	fallback *ring.Ring
-	nWrites  atomic.Uint64
+	rings     map[string]*part
+	fallback  *part
+	wal       *wal.Wal
+	walMu     sync.RWMutex // held exclusively to checkpoint
+	nWrites   atomic.Uint64
+	quit      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
 }
 
 type Cfg struct {
-	RingSizes    map[string]uint32 `yaml:"ring_sizes"`
-	FallbackSize uint32            `yaml:"fallback_size"`
+	RingSizes     map[string]uint32     `yaml:"ring_sizes"`     // slots, by ring key
+	RingBytes     map[string]ByteSize   `yaml:"ring_bytes"`     // bytes, by ring key
+	FallbackSize  uint32                `yaml:"fallback_size"`  // slots
+	FallbackBytes ByteSize              `yaml:"fallback_bytes"` // bytes, instead of slots if set
+	DataDir       string                `yaml:"data_dir"`       // no persistence if empty
+	SegmentSize   int64                 `yaml:"segment_size"`   // rotate segment files after
+	Retention     map[string]*Retention `yaml:"retention"`      // by ring key, or _fallback
+	CompactEvery  time.Duration         `yaml:"compact_every"`  // enforce retention every
+	Wal           *wal.Cfg              `yaml:"wal"`
+	Index         []string              `yaml:"index"` // ring keys to index tokens of
+}
+
+// buffer is a ring bounded by number of entries, or by bytes
+type buffer interface {
+	Write(t time.Time, data []byte)
+	Read(offset, limit uint32) <-chan []byte
+	Head() uint32
+	Size() uint32
+	Len() uint32
+	Get(seq uint64) ([]byte, bool)
+	Time(seq uint64) (time.Time, bool)
+	Seq() uint64
+	SetSeq(seq uint64)
 }
 
-func NewStore(cfg *Cfg) *Store {
+// part is a ring, and optionally the log on disk behind it
+type part struct {
+	key       string
+	ring      buffer
+	log       *segment.Log
+	retention *Retention
+	index     *index        // nil if not indexed
+	mu        sync.Mutex    // orders persisted writes
+	floor     atomic.Uint64 // seq of oldest record within retention
+	nWrites   atomic.Uint64
+}
+
+func NewStore(cfg *Cfg) (*Store, error) {
 	s := &Store{
-		rings:    make(map[string]*ring.Ring, len(cfg.RingSizes)),
-		fallback: ring.NewRing(cfg.FallbackSize),
+		rings: make(map[string]*part, len(cfg.RingSizes)+len(cfg.RingBytes)),
+		quit:  make(chan struct{}),
+	}
+	opened := false
+	defer func() {
+		if !opened {
+			s.release()
+		}
+	}()
+	var err error
+	var fallbackRing buffer = ring.NewRing(cfg.FallbackSize)
+	if cfg.FallbackBytes > 0 {
+		if cfg.FallbackBytes > math.MaxUint32 {
+			return nil, fmt.Errorf("fallback_bytes %d is over the max of %d", cfg.FallbackBytes, uint32(math.MaxUint32))
+		}
+		fallbackRing = ring.NewByteRing(uint32(cfg.FallbackBytes))
+	}
+	s.fallback, err = newPart(cfg, fallbackKey, fallbackRing)
+	if err != nil {
+		return nil, err
 	}
 	for key, size := range cfg.RingSizes {
-		s.rings[key] = ring.NewRing(size)
+		if _, ok := cfg.RingBytes[key]; ok {
+			return nil, fmt.Errorf("ring %q has both size and bytes", key)
+		}
+		s.rings[key], err = newPart(cfg, key, ring.NewRing(size))
+		if err != nil {
+			return nil, err
+		}
 	}
-	return s
+	for key, size := range cfg.RingBytes {
+		if size > math.MaxUint32 {
+			return nil, fmt.Errorf("ring %q of %d bytes is over the max of %d", key, size, uint32(math.MaxUint32))
+		}
+		s.rings[key], err = newPart(cfg, key, ring.NewByteRing(uint32(size)))
+		if err != nil {
+			return nil, err
+		}
+	}
+	compactEvery := cfg.CompactEvery
+	if compactEvery <= 0 {
+		compactEvery = defaultCompactEvery
+	}
+	if cfg.DataDir != "" {
+		walCfg := cfg.Wal
+		if walCfg == nil {
+			walCfg = &wal.Cfg{Sync: wal.SyncInterval, SyncEvery: 100 * time.Millisecond}
+		}
+		s.wal, err = wal.Open(filepath.Join(cfg.DataDir, walFname), walCfg)
+		if err != nil {
+			return nil, fmt.Errorf("err opening wal: %w", err)
+		}
+		err = s.recover()
+		if err != nil {
+			return nil, fmt.Errorf("err recovering from wal: %w", err)
+		}
+	}
+	opened = true
+	go s.compactEvery(compactEvery)
+	return s, nil
 }
 
-// Write writes to the ring of key, or fallback ring
-func (s *Store) Write(key string, data []byte) {
-	s.nWrites.Add(uint64(1))
-	part, ok := s.rings[key]
+// release closes the files opened by NewStore, if it fails
+func (s *Store) release() {
+	if s.wal != nil {
+		s.wal.Close()
+	}
+	for _, p := range s.rings {
+		if p != nil && p.log != nil {
+			p.log.Close()
+		}
+	}
+	if s.fallback != nil && s.fallback.log != nil {
+		s.fallback.log.Close()
+	}
+}
+
+// recover replays writes from the wal that did not make it
+// into the logs, then checkpoints. A ring missing records before
+// those in the wal is not replayed to, as the seqs would not match.
+func (s *Store) recover() error {
+	var replayed int
+	gaps := make(map[string]bool)
+	err := s.wal.Replay(func(r *wal.Record) error {
+		p := s.part(r.Key)
+		if r.N < p.ring.Seq() {
+			return nil // already in log
+		}
+		if r.N > p.ring.Seq() {
+			if !gaps[p.key] {
+				fmt.Printf("wal record %d of %s is after missing record %d, not replaying %s\n", r.N, p.key, p.ring.Seq(), p.key)
+				gaps[p.key] = true
+			}
+			return nil
+		}
+		replayed++
+		return p.write(r.T, r.Data)
+	})
+	if err != nil {
+		return err
+	}
+	if replayed > 0 {
+		fmt.Printf("replayed %d writes from wal\n", replayed)
+	}
+	return s.checkpoint()
+}
+
+// checkpoint syncs all logs to disk, then resets the wal
+func (s *Store) checkpoint() error {
+	for _, p := range s.parts() {
+		err := p.log.Sync()
+		if err != nil {
+			return fmt.Errorf("err syncing log: %w", err)
+		}
+	}
+	return s.wal.Reset()
+}
+
+func newPart(cfg *Cfg, key string, r buffer) (*part, error) {
+	p := &part{
+		key:       key,
+		ring:      r,
+		retention: cfg.Retention[key],
+	}
+	if slices.Contains(cfg.Index, key) {
+		p.index = newIndex(0)
+	}
+	if cfg.DataDir == "" {
+		return p, nil
+	}
+	segmentSize := cfg.SegmentSize
+	if segmentSize <= 0 {
+		segmentSize = defaultSegmentSize
+	}
+	dir := filepath.Join(cfg.DataDir, url.PathEscape(key))
+	var err error
+	p.log, err = segment.Open(dir, segmentSize)
+	if err != nil {
+		return nil, fmt.Errorf("err opening log of %q: %w", key, err)
+	}
+	err = p.load()
+	if err != nil {
+		p.log.Close()
+		return nil, fmt.Errorf("err loading ring %q: %w", key, err)
+	}
+	return p, nil
+}
+
+// load fills the ring with the latest records from disk,
+// so that the seq of each entry is its record number
+func (p *part) load() error {
+	count := p.log.Count()
+	start, err := p.loadStart(count)
+	if err != nil {
+		return err
+	}
+	p.ring.SetSeq(start)
+	if p.index != nil {
+		p.index.first = start
+	}
+	for i := start; i < count; i++ {
+		data, t, err := p.log.Read(i)
+		if err != nil {
+			return fmt.Errorf("err reading record %d: %w", i, err)
+		}
+		p.push(t, data)
+	}
+	return nil
+}
+
+// loadStart returns the number of the oldest record that will fit in the ring
+func (p *part) loadStart(count uint64) (uint64, error) {
+	first := p.log.First()
+	if _, ok := p.ring.(*ring.ByteRing); !ok {
+		return max(first, count-min(count, uint64(p.ring.Size()))), nil
+	}
+	var bytes uint64
+	start := count
+	for start > first {
+		data, _, err := p.log.Read(start - 1)
+		if err != nil {
+			return 0, fmt.Errorf("err reading record %d: %w", start-1, err)
+		}
+		bytes += uint64(len(data))
+		if bytes > uint64(p.ring.Size()) {
+			break
+		}
+		start--
+	}
+	return start, nil
+}
+
+func (p *part) write(t time.Time, data []byte) error {
+	if p.log != nil {
+		err := p.log.Append(t, data)
+		if err != nil {
+			return fmt.Errorf("err appending to log: %w", err)
+		}
+	}
+	p.push(t, data)
+	return nil
+}
+
+// get returns record seq from the ring, or from disk
+func (p *part) get(seq uint64) ([]byte, error) {
+	data, ok := p.ring.Get(seq)
+	if ok {
+		return data, nil
+	}
+	if p.log == nil {
+		return nil, fmt.Errorf("record %d not in ring", seq)
+	}
+	data, _, err := p.log.Read(seq)
+	return data, err
+}
+
+// part returns the part of key, or fallback
+func (s *Store) part(key string) *part {
+	p, ok := s.rings[key]
 	if !ok {
-		s.fallback.Write(data)
-		return
+		return s.fallback
+	}
+	return p
+}
+
+func (s *Store) parts() []*part {
+	parts := make([]*part, 0, len(s.rings)+1)
+	for _, p := range s.rings {
+		parts = append(parts, p)
 	}
-	part.Write(data)
+	return append(parts, s.fallback)
+}
+
+// Write writes to the ring of key, or fallback ring.
+// If persisting, the write goes through the wal first.
+// It is safe to call concurrently.
+func (s *Store) Write(key string, t time.Time, data []byte) error {
+	s.nWrites.Add(uint64(1))
+	p := s.part(key)
+	p.nWrites.Add(1)
+	if s.wal == nil {
+		return p.write(t, data)
+	}
+	err := s.persist(p, t, data)
+	if err != nil {
+		return err
+	}
+	if s.wal.Size() > walCheckpointSize {
+		s.walMu.Lock()
+		defer s.walMu.Unlock()
+		if s.wal.Size() > walCheckpointSize {
+			return s.checkpoint()
+		}
+	}
+	return nil
+}
+
+// persist writes through the wal to the part
+func (s *Store) persist(p *part, t time.Time, data []byte) error {
+	s.walMu.RLock()
+	defer s.walMu.RUnlock()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := s.wal.Append(&wal.Record{Key: p.key, N: p.ring.Seq(), T: t, Data: data})
+	if err != nil {
+		return fmt.Errorf("err writing to wal: %w", err)
+	}
+	return p.write(t, data)
 }
 
 func (s *Store) HeadsAndSizes() map[string][2]uint32 {
 	info := make(map[string][2]uint32, len(s.rings)+1)
-	for key, ring := range s.rings {
-		info[key] = [2]uint32{ring.Head(), ring.Size()}
+	for key, part := range s.rings {
+		info[key] = [2]uint32{part.ring.Head(), part.ring.Size()}
 	}
-	info["_fallback"] = [2]uint32{s.fallback.Head(), s.fallback.Size()}
+	info[fallbackKey] = [2]uint32{s.fallback.ring.Head(), s.fallback.ring.Size()}
 	return info
 }
 
-// Read reads up to limit items, from offset,
-// all rings with the given key prefix
-func (s *Store) Read(keyPrefix string, offset, limit uint32) <-chan []byte {
-	out := make(chan []byte, 1)
-	go func() {
-		defer close(out)
-		exactRing := s.rings[keyPrefix]
-		if exactRing != nil {
-			for d := range exactRing.Read(offset, limit) {
-				out <- d
-			}
-			return
-		}
-		var count uint32
-		var matchedPrefix bool
-		for key, r := range s.rings {
-			if strings.HasPrefix(key, keyPrefix) {
-				matchedPrefix = true
-				fmt.Println("reading from", key)
-				for d := range r.Read(offset, limit-count) {
-					out <- d
-					count++
-					if count >= limit {
-						return
-					}
-				}
-			}
-		}
-		if !matchedPrefix {
-			fmt.Println("reading from fallback")
-			for d := range s.fallback.Read(offset, limit) {
-				out <- d
-			}
-		}
-	}()
-	return out
+// Heads returns the seq of the next write to each ring, the cursor of
+// a read, oldest first, of only the records written from now on
+func (s *Store) Heads() Cursor {
+	heads := make(Cursor, len(s.rings)+1)
+	for _, p := range s.parts() {
+		heads[p.key] = p.ring.Seq()
+	}
+	return heads
+}
+
+// Head returns the ring that records of key are written to,
+// and the seq of its next write
+func (s *Store) Head(key string) (string, uint64) {
+	p := s.part(key)
+	return p.key, p.ring.Seq()
 }
 
 func (s *Store) NWrites() uint64 {
 	return s.nWrites.Load()
 }
+
+// WritesByRing returns the number of writes to each ring since start
+func (s *Store) WritesByRing() map[string]uint64 {
+	writes := make(map[string]uint64, len(s.rings)+1)
+	for key, part := range s.rings {
+		writes[key] = part.nWrites.Load()
+	}
+	writes[fallbackKey] = s.fallback.nWrites.Load()
+	return writes
+}
+
+// Close stops compaction, checkpoints, and closes the logs on disk.
+// It may be called more than once, returning the same error.
+func (s *Store) Close() error {
+	s.closeOnce.Do(func() { s.closeErr = s.close() })
+	return s.closeErr
+}
+
+func (s *Store) close() error {
+	close(s.quit)
+	if s.wal == nil {
+		return nil
+	}
+	s.walMu.Lock()
+	defer s.walMu.Unlock()
+	err := s.checkpoint()
+	if err != nil {
+		return err
+	}
+	errs := []error{s.wal.Close()}
+	for _, p := range s.parts() {
+		errs = append(errs, p.log.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,297 @@
+package store
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/wal"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestReadFallsThroughToDisk(t *testing.T) {
+	cfg := &Cfg{
+		RingSizes:    map[string]uint32{"/test/app": 10},
+		FallbackSize: 10,
+		DataDir:      t.TempDir(),
+		SegmentSize:  1024,
+	}
+	s, err := NewStore(cfg)
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, s.Write("/test/app", time.Now(), []byte(fmt.Sprintf("%d", i))))
+	}
+	got := readAll(s.Read(&Query{KeyPrefix: "/test/app", Offset: 5, Limit: 20}))
+	require.Len(t, got, 20)
+	for i, d := range got {
+		require.Equal(t, fmt.Sprintf("%d", 94-i), d)
+	}
+	require.NoError(t, s.Close())
+
+	// rings are rebuilt from disk
+	s, err = NewStore(cfg)
+	require.NoError(t, err)
+	defer s.Close()
+	got = readAll(s.Read(&Query{KeyPrefix: "/test/app", Offset: 0, Limit: 3}))
+	require.Equal(t, []string{"99", "98", "97"}, got)
+}
+
+func readAll(res *Result) []string {
+	out := make([]string, 0)
+	for e := range res.Entries {
+		out = append(out, string(e.Data))
+	}
+	return out
+}
+
+func TestRecoverFromWal(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Cfg{
+		RingSizes:    map[string]uint32{"/test/app": 100},
+		FallbackSize: 10,
+		DataDir:      dir,
+		Wal:          &wal.Cfg{Sync: wal.SyncAlways},
+	}
+	s, err := NewStore(cfg)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, s.Write("/test/app", time.Now(), []byte(fmt.Sprintf("%d", i))))
+	}
+	// simulate a crash that lost the tail of the segment, but not the wal
+	segName := filepath.Join(dir, url.PathEscape("/test/app"), fmt.Sprintf("%020d.seg", 0))
+	info, err := os.Stat(segName)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(segName, info.Size()-10))
+
+	s, err = NewStore(cfg)
+	require.NoError(t, err)
+	defer s.Close()
+	got := readAll(s.Read(&Query{KeyPrefix: "/test/app", Offset: 0, Limit: 100}))
+	require.Len(t, got, 10)
+	for i, d := range got {
+		require.Equal(t, fmt.Sprintf("%d", 9-i), d)
+	}
+}
+
+func TestRecoverSkipsGap(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Cfg{
+		RingSizes:    map[string]uint32{"/test/app": 100},
+		FallbackSize: 10,
+		DataDir:      dir,
+		Wal:          &wal.Cfg{Sync: wal.SyncAlways},
+	}
+	s, err := NewStore(cfg)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.Write("/test/app", time.Now(), []byte(fmt.Sprintf("%d", i))))
+	}
+	require.NoError(t, s.Close()) // checkpoints, so the wal begins at 5
+	s, err = NewStore(cfg)
+	require.NoError(t, err)
+	for i := 5; i < 10; i++ {
+		require.NoError(t, s.Write("/test/app", time.Now(), []byte(fmt.Sprintf("%d", i))))
+	}
+	// simulate losing the segment, leaving the wal records after a gap
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, url.PathEscape("/test/app"))))
+
+	s, err = NewStore(cfg)
+	require.NoError(t, err)
+	defer s.Close()
+	require.Empty(t, readAll(s.Read(&Query{KeyPrefix: "/test/app", Limit: 100})))
+	require.Equal(t, uint64(0), s.Heads()["/test/app"])
+}
+
+func TestByteRing(t *testing.T) {
+	cfg := &Cfg{
+		RingBytes:    map[string]ByteSize{"/test/app": 20},
+		FallbackSize: 10,
+		DataDir:      t.TempDir(),
+	}
+	s, err := NewStore(cfg)
+	require.NoError(t, err)
+	for i := 10; i < 30; i++ {
+		require.NoError(t, s.Write("/test/app", time.Now(), []byte(fmt.Sprintf("%d", i))))
+	}
+	// 10 entries of 2 bytes fit in the ring, the rest are read from disk
+	require.Equal(t, uint32(10), s.rings["/test/app"].ring.Len())
+	got := readAll(s.Read(&Query{KeyPrefix: "/test/app", Offset: 8, Limit: 4}))
+	require.Equal(t, []string{"21", "20", "19", "18"}, got)
+	require.NoError(t, s.Close())
+
+	s, err = NewStore(cfg)
+	require.NoError(t, err)
+	defer s.Close()
+	require.Equal(t, uint32(10), s.rings["/test/app"].ring.Len())
+	got = readAll(s.Read(&Query{KeyPrefix: "/test/app", Offset: 0, Limit: 2}))
+	require.Equal(t, []string{"29", "28"}, got)
+}
+
+func TestByteRingTooLarge(t *testing.T) {
+	_, err := NewStore(&Cfg{RingBytes: map[string]ByteSize{"/test/app": 4 << 30}, FallbackSize: 10})
+	require.ErrorContains(t, err, "over the max")
+	_, err = NewStore(&Cfg{FallbackBytes: 4 << 30})
+	require.ErrorContains(t, err, "over the max")
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	cfg := &Cfg{
+		RingSizes:    map[string]uint32{"/test/app": 100},
+		FallbackSize: 10,
+		DataDir:      t.TempDir(),
+	}
+	s, err := NewStore(cfg)
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if err := s.Write("/test/app", time.Now(), []byte("test")); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, map[string]uint64{"/test/app": 800, fallbackKey: 0}, s.WritesByRing())
+	require.NoError(t, s.Close())
+	s, err = NewStore(cfg)
+	require.NoError(t, err)
+	defer s.Close()
+	require.Equal(t, uint64(800), s.rings["/test/app"].ring.Seq())
+	require.Len(t, readAll(s.Read(&Query{KeyPrefix: "/test/app", Offset: 0, Limit: 1000})), 800)
+}
+
+func TestCursor(t *testing.T) {
+	s, err := NewStore(&Cfg{
+		RingSizes:    map[string]uint32{"/test/a": 100, "/test/b": 100},
+		FallbackSize: 10,
+	})
+	require.NoError(t, err)
+	defer s.Close()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, s.Write("/test/a", time.Now(), []byte(fmt.Sprintf("a%d", i))))
+		require.NoError(t, s.Write("/test/b", time.Now(), []byte(fmt.Sprintf("b%d", i))))
+	}
+	res := s.Read(&Query{KeyPrefix: "/test", Limit: 12})
+	page := readAll(res)
+	require.Len(t, page, 12)
+	cursor, err := ParseCursor(res.Cursor().Bytes())
+	require.NoError(t, err)
+	// new writes must not shift the next page
+	require.NoError(t, s.Write("/test/a", time.Now(), []byte("new")))
+	res = s.Read(&Query{KeyPrefix: "/test", Limit: 100, Cursor: cursor})
+	page = append(page, readAll(res)...)
+	require.Len(t, page, 20)
+	seen := make(map[string]bool)
+	for _, d := range page {
+		require.False(t, seen[d], "duplicate %s", d)
+		seen[d] = true
+	}
+	require.False(t, seen["new"])
+}
+
+func TestMergeByTime(t *testing.T) {
+	s, err := NewStore(&Cfg{
+		RingSizes:    map[string]uint32{"/test/a": 100, "/test/b": 100},
+		FallbackSize: 10,
+	})
+	require.NoError(t, err)
+	defer s.Close()
+	start := time.Now()
+	// a gets even seconds, b odd, interleaved in time but not in ring
+	for i := 0; i < 10; i++ {
+		key := "/test/a"
+		if i%2 == 1 {
+			key = "/test/b"
+		}
+		msgT := start.Add(time.Duration(i) * time.Second)
+		data, err := proto.Marshal(&cmd.Msg{T: timestamppb.New(msgT), Txt: fmt.Sprintf("%d", i)})
+		require.NoError(t, err)
+		require.NoError(t, s.Write(key, msgT, data))
+	}
+	txts := func(res *Result) []string {
+		out := make([]string, 0)
+		for e := range res.Entries {
+			out = append(out, e.Msg.GetTxt())
+		}
+		return out
+	}
+	require.Equal(t, []string{"9", "8", "7"}, txts(s.Read(&Query{KeyPrefix: "/test", Limit: 3})))
+	res := s.Read(&Query{KeyPrefix: "/test", Limit: 4, OldestFirst: true})
+	require.Equal(t, []string{"0", "1", "2", "3"}, txts(res))
+	res = s.Read(&Query{KeyPrefix: "/test", Limit: 10, OldestFirst: true, Cursor: res.Cursor()})
+	require.Equal(t, []string{"4", "5", "6", "7", "8", "9"}, txts(res))
+	odd := func(m *cmd.Msg) bool {
+		return m.GetT().AsTime().Sub(start)/time.Second%2 == 1
+	}
+	res = s.Read(&Query{KeyPrefix: "/test", Offset: 1, Limit: 2, Match: odd})
+	require.Equal(t, []string{"7", "5"}, txts(res))
+}
+
+func TestReadSince(t *testing.T) {
+	s, err := NewStore(&Cfg{FallbackSize: 10})
+	require.NoError(t, err)
+	defer s.Close()
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		msgT := start.Add(time.Duration(i) * time.Second)
+		data, err := proto.Marshal(&cmd.Msg{T: timestamppb.New(msgT), Txt: fmt.Sprintf("%d", i)})
+		require.NoError(t, err)
+		require.NoError(t, s.Write("/test/app", msgT, data))
+	}
+	heads := s.Heads()
+	require.Equal(t, Cursor{fallbackKey: 5}, heads)
+	res := s.Read(&Query{Limit: 10, OldestFirst: true, Since: start.Add(3 * time.Second)})
+	txts := make([]string, 0)
+	for e := range res.Entries {
+		txts = append(txts, e.Msg.GetTxt())
+	}
+	require.Equal(t, []string{"3", "4"}, txts)
+	res = s.Read(&Query{Limit: 10, OldestFirst: true, Cursor: heads})
+	require.Empty(t, readAll(res))
+
+	// stops at the first msg after Until, not reading those left
+	res = s.Read(&Query{Limit: 10, OldestFirst: true, Since: start.Add(time.Second), Until: start.Add(2 * time.Second)})
+	txts = txts[:0]
+	for e := range res.Entries {
+		txts = append(txts, e.Msg.GetTxt())
+	}
+	require.Equal(t, []string{"1", "2"}, txts)
+	require.Equal(t, Cursor{fallbackKey: 3}, res.Cursor())
+
+	// stops at End, rather than at the records written since
+	data, err := proto.Marshal(&cmd.Msg{T: timestamppb.Now(), Txt: "5"})
+	require.NoError(t, err)
+	require.NoError(t, s.Write("/test/app", time.Now(), data))
+	res = s.Read(&Query{Limit: 10, OldestFirst: true, Cursor: Cursor{fallbackKey: 3}, End: heads})
+	txts = txts[:0]
+	for e := range res.Entries {
+		txts = append(txts, e.Msg.GetTxt())
+	}
+	require.Equal(t, []string{"3", "4"}, txts)
+	require.Equal(t, Cursor{fallbackKey: 5}, res.Cursor())
+}
+
+func TestDrain(t *testing.T) {
+	s, err := NewStore(&Cfg{FallbackSize: 10})
+	require.NoError(t, err)
+	defer s.Close()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, s.Write("/test/app", time.Now(), []byte(fmt.Sprintf("%d", i))))
+	}
+	res := s.Read(&Query{Limit: 5})
+	<-res.Entries
+	res.Drain()
+	_, ok := <-res.Entries
+	require.False(t, ok)
+}
@@ -0,0 +1,152 @@
+package udp
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"slices"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/pkg"
+	"github.com/intob/logd/store"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// aggregateFragmentOverhead is the most that a reply
+// adds to a fragment of an aggregate reply
+const aggregateFragmentOverhead = 48
+
+// Aggregate counts the msgs matching the query, up to the
+// aggregate hard limit, grouped as asked by agg
+func (svc *UdpSvc) Aggregate(query *cmd.QueryParams, agg *cmd.Aggregation) (*cmd.AggregateResult, error) {
+	f, err := newFilter(query)
+	if err != nil {
+		return nil, err
+	}
+	limit := query.GetLimit()
+	if limit == 0 || limit > svc.aggregateHardLimit {
+		limit = svc.aggregateHardLimit
+	}
+	// read oldest first, from tStart until tEnd, so that
+	// a narrow range doesn't scan the whole store
+	q := &store.Query{
+		KeyPrefix:   query.GetKeyPrefix(),
+		Offset:      query.GetOffset(),
+		Limit:       limit,
+		OldestFirst: true,
+		Txt:         query.GetTxt(),
+		Match: func(msg *cmd.Msg) bool {
+			return msgMatchesQuery(msg, f)
+		},
+	}
+	if tStart := tStart(query); tStart != nil {
+		q.Since = *tStart
+	}
+	if tEnd := tEnd(query); tEnd != nil {
+		q.Until = *tEnd
+	}
+	res := svc.logStore.Aggregate(q, &store.Aggregation{
+		ByKey:    agg.GetByKey(),
+		KeyDepth: int(agg.GetKeyDepth()),
+		ByLvl:    agg.GetByLvl(),
+		Bucket:   time.Duration(agg.GetBucket()) * time.Second,
+		TopKeys:  int(agg.GetTopKeys()),
+	})
+	return aggregateResult(res, agg.GetBucket() > 0), nil
+}
+
+// aggregateResult returns the counts ordered by time, key & lvl
+func aggregateResult(res *store.Aggregate, bucketed bool) *cmd.AggregateResult {
+	out := &cmd.AggregateResult{
+		Matched:   res.Matched,
+		Truncated: res.Truncated,
+	}
+	groups := make([]store.Group, 0, len(res.Counts))
+	for g := range res.Counts {
+		groups = append(groups, g)
+	}
+	slices.SortFunc(groups, func(a, b store.Group) int {
+		return cmp.Or(a.T.Compare(b.T), cmp.Compare(a.Key, b.Key), cmp.Compare(a.Lvl, b.Lvl))
+	})
+	for _, g := range groups {
+		c := &cmd.Count{Key: g.Key, Lvl: g.Lvl, N: res.Counts[g]}
+		if bucketed {
+			c.T = timestamppb.New(g.T)
+		}
+		out.Counts = append(out.Counts, c)
+	}
+	for _, k := range res.TopKeys {
+		out.TopKeys = append(out.TopKeys, &cmd.Count{Key: k.Key, N: k.N})
+	}
+	return out
+}
+
+func (svc *UdpSvc) handleAggregate(command *cmd.Cmd, raddr netip.AddrPort) {
+	start := time.Now()
+	res, err := svc.Aggregate(command.GetQueryParams(), command.GetAggregation())
+	if err != nil {
+		svc.replyErr(err, raddr, command.Id)
+		return
+	}
+	svc.ObserveQuery("udp", time.Since(start))
+	err = svc.sendAggregate(command.GetQueryParams().GetCompression(), command.Id, res, raddr)
+	if err != nil {
+		fmt.Printf("err sending aggregate to %s: %v\n", raddr, err)
+	}
+}
+
+// sendAggregate sends the result as one reply if it fits,
+// otherwise the packed reply in fragments, each a reply
+func (svc *UdpSvc) sendAggregate(c cmd.Compression, id uint64, res *cmd.AggregateResult, raddr netip.AddrPort) error {
+	raw, err := proto.Marshal(&cmd.Reply{Id: id, Aggregate: res})
+	if err != nil {
+		return fmt.Errorf("err marshaling reply: %w", err)
+	}
+	packed, err := pkg.Pack(c, raw)
+	if err != nil {
+		return fmt.Errorf("err compressing reply: %w", err)
+	}
+	if c != cmd.Compression_NONE {
+		svc.compressStats.replyRaw.Add(uint64(len(raw)))
+		svc.compressStats.replyPacked.Add(uint64(len(packed)))
+	}
+	if len(packed) <= svc.packetBufferSize {
+		err = svc.writeTo(packed, raddr)
+		if err != nil {
+			return fmt.Errorf("err writing to conn: %w", err)
+		}
+		return nil
+	}
+	chunk := svc.packetBufferSize - aggregateFragmentOverhead
+	total := (len(packed) + chunk - 1) / chunk
+	if total > MaxFragments {
+		return fmt.Errorf("aggregate of %d bytes is too large", len(packed))
+	}
+	fragmentId := rand.Uint64() // distinct per attempt of the client
+	for i := 0; i < total; i++ {
+		raw, err := proto.Marshal(&cmd.Reply{
+			Id: id,
+			Fragment: &cmd.Fragment{
+				Id:    fragmentId,
+				Index: uint32(i),
+				Total: uint32(total),
+				Data:  packed[i*chunk : min((i+1)*chunk, len(packed))],
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("err marshaling fragment: %w", err)
+		}
+		fragment, err := pkg.Pack(cmd.Compression_NONE, raw)
+		if err != nil {
+			return fmt.Errorf("err packing fragment: %w", err)
+		}
+		err = svc.writeTo(fragment, raddr)
+		if err != nil {
+			return fmt.Errorf("err writing to conn: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package udp
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/pkg"
+	"github.com/intob/logd/store"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestSendAggregateFragments(t *testing.T) {
+	s, err := store.NewStore(&store.Cfg{FallbackSize: 1000})
+	require.NoError(t, err)
+	defer s.Close()
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("/test/app%d", i%50)
+		msg := &cmd.Msg{Key: key, Lvl: cmd.Lvl_INFO, T: timestamppb.Now()}
+		data, err := proto.Marshal(msg)
+		require.NoError(t, err)
+		require.NoError(t, s.Write(key, msg.T.AsTime(), data))
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer client.Close()
+	svc := &UdpSvc{conn: conn, packetBufferSize: 256, aggregateHardLimit: 1000, logStore: s, metrics: newMetrics()}
+	res, err := svc.Aggregate(&cmd.QueryParams{KeyPrefix: proto.String("/test")}, &cmd.Aggregation{ByKey: true, TopKeys: 3})
+	require.NoError(t, err)
+	require.Equal(t, uint64(100), res.Matched)
+	require.Len(t, res.Counts, 50)
+	require.Equal(t, "/test/app0", res.Counts[0].Key)
+	require.Equal(t, uint64(2), res.Counts[0].N)
+	require.Len(t, res.TopKeys, 3)
+
+	require.NoError(t, svc.sendAggregate(cmd.Compression_NONE, 7, res, client.LocalAddr().(*net.UDPAddr).AddrPort()))
+	var payload []byte
+	buf := make([]byte, 512)
+	for {
+		require.NoError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+		n, err := client.Read(buf)
+		require.NoError(t, err)
+		require.LessOrEqual(t, n, 256)
+		raw, err := pkg.Unpacked(buf[:n])
+		require.NoError(t, err)
+		reply := &cmd.Reply{}
+		require.NoError(t, proto.Unmarshal(raw, reply))
+		require.Equal(t, uint64(7), reply.Id)
+		require.NotNil(t, reply.Fragment)
+		payload = append(payload, reply.Fragment.Data...)
+		if reply.Fragment.Index == reply.Fragment.Total-1 {
+			break
+		}
+	}
+	raw, err := pkg.Unpacked(payload)
+	require.NoError(t, err)
+	reply := &cmd.Reply{}
+	require.NoError(t, proto.Unmarshal(raw, reply))
+	require.True(t, proto.Equal(res, reply.Aggregate))
+}
@@ -0,0 +1,90 @@
+package udp
+
+import (
+	"fmt"
+	"net/netip"
+	"sync/atomic"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/pkg"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxReplyRatio bounds the msgs batched into a compressed
+// reply, before compressing to see if they fit
+const maxReplyRatio = 8
+
+// compressStats counts bytes before & after compression
+type compressStats struct {
+	cmdRaw, cmdPacked     atomic.Uint64
+	replyRaw, replyPacked atomic.Uint64
+}
+
+// CompressionRatios returns the ratio of raw to compressed bytes of
+// received cmds, and of sent replies, or 0 if none were compressed
+func (svc *UdpSvc) CompressionRatios() (cmds, replies float64) {
+	return ratio(&svc.compressStats.cmdRaw, &svc.compressStats.cmdPacked),
+		ratio(&svc.compressStats.replyRaw, &svc.compressStats.replyPacked)
+}
+
+func ratio(raw, packed *atomic.Uint64) float64 {
+	p := packed.Load()
+	if p == 0 {
+		return 0
+	}
+	return float64(raw.Load()) / float64(p)
+}
+
+// decompress returns the payload of a cmd, decompressed by c
+func (svc *UdpSvc) decompress(c cmd.Compression, payload []byte) ([]byte, error) {
+	if c == cmd.Compression_NONE {
+		return payload, nil
+	}
+	raw, err := pkg.Decompress(c, payload)
+	if err != nil {
+		return nil, err
+	}
+	svc.compressStats.cmdRaw.Add(uint64(len(raw)))
+	svc.compressStats.cmdPacked.Add(uint64(len(payload)))
+	return raw, nil
+}
+
+// packReply returns msgs as a reply to the cmd of id,
+// behind the flag byte, compressed if c is set
+func (svc *UdpSvc) packReply(c cmd.Compression, id uint64, msgs []*cmd.Msg) ([]byte, error) {
+	raw, err := proto.Marshal(&cmd.Reply{Id: id, Msgs: msgs})
+	if err != nil {
+		return nil, fmt.Errorf("err marshaling reply: %w", err)
+	}
+	packed, err := pkg.Pack(c, raw)
+	if err != nil {
+		return nil, fmt.Errorf("err compressing reply: %w", err)
+	}
+	return packed, nil
+}
+
+// sendReply sends msgs as replies to the cmd of id, halving
+// the batch until each fits the packet buffer size
+func (svc *UdpSvc) sendReply(c cmd.Compression, id uint64, msgs []*cmd.Msg, raddr netip.AddrPort) error {
+	packed, err := svc.packReply(c, id, msgs)
+	if err != nil {
+		return err
+	}
+	if len(packed) > svc.packetBufferSize && len(msgs) > 1 {
+		half := len(msgs) / 2
+		err = svc.sendReply(c, id, msgs[:half], raddr)
+		if err != nil {
+			return err
+		}
+		return svc.sendReply(c, id, msgs[half:], raddr)
+	}
+	if c != cmd.Compression_NONE {
+		svc.compressStats.replyRaw.Add(uint64(proto.Size(&cmd.Reply{Id: id, Msgs: msgs})))
+		svc.compressStats.replyPacked.Add(uint64(len(packed)))
+	}
+	err = svc.writeTo(packed, raddr)
+	if err != nil {
+		return fmt.Errorf("err writing to conn: %w", err)
+	}
+	return nil
+}
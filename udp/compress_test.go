@@ -0,0 +1,55 @@
+package udp
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/guard"
+	"github.com/intob/logd/pkg"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestReadPacketVerifiesBeforeDecompressing(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+	client, err := net.DialUDP("udp", nil, conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer client.Close()
+	svc := &UdpSvc{
+		conn:             conn,
+		packetBufferSize: 1460,
+		secrets:          &Secrets{Read: "read", Write: "write"},
+		guard:            guard.NewGuard(context.Background(), &guard.Cfg{FilterCap: 1000, FilterTtl: time.Minute, PacketTtl: time.Minute}),
+		write:            make(chan *cmd.Msg, 1),
+		metrics:          newMetrics(),
+		pkgPool:          &sync.Pool{New: func() any { return &pkg.Pkg{} }},
+	}
+	raw, err := proto.Marshal(&cmd.Cmd{Name: cmd.Name_WRITE, Msg: &cmd.Msg{Txt: "hi"}})
+	require.NoError(t, err)
+	payload, err := pkg.Compress(cmd.Compression_ZSTD, raw)
+	require.NoError(t, err)
+
+	// not decompressed, as not signed by either secret
+	_, err = client.Write(pkg.SignCompressed([]byte("other"), cmd.Compression_ZSTD, payload))
+	require.NoError(t, err)
+	require.NoError(t, svc.readPacket())
+	require.Equal(t, uint64(0), svc.compressStats.cmdRaw.Load())
+	// a write needs the write secret
+	_, err = client.Write(pkg.SignCompressed([]byte("read"), cmd.Compression_ZSTD, payload))
+	require.NoError(t, err)
+	require.NoError(t, svc.readPacket())
+	require.Len(t, svc.write, 0)
+	require.Equal(t, uint64(2), svc.Metrics().Rejected["signature"])
+
+	_, err = client.Write(pkg.SignCompressed([]byte("write"), cmd.Compression_ZSTD, payload))
+	require.NoError(t, err)
+	require.NoError(t, svc.readPacket())
+	require.Equal(t, "hi", (<-svc.write).Txt)
+	require.Equal(t, uint64(2*len(raw)), svc.compressStats.cmdRaw.Load())
+}
@@ -0,0 +1,134 @@
+package udp
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/intob/logd/cmd"
+	"google.golang.org/protobuf/proto"
+)
+
+// filter is the query params of a query or tail, with the
+// txt regex compiled once, as the query or tail is accepted
+type filter struct {
+	q  *cmd.QueryParams
+	re *regexp.Regexp // nil if none
+}
+
+func newFilter(q *cmd.QueryParams) (*filter, error) {
+	f := &filter{q: q}
+	expr := q.GetTxtRegex()
+	if expr == "" {
+		return f, nil
+	}
+	if q.GetTxtIgnoreCase() {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("err compiling txt regex: %w", err)
+	}
+	f.re = re
+	return f, nil
+}
+
+// txtMatches returns true if the msg txt satisfies
+// the substring and regex filters of the query
+func txtMatches(msg *cmd.Msg, f *filter) bool {
+	txt := msg.GetTxt()
+	if sub := f.q.GetTxt(); sub != "" {
+		if f.q.GetTxtIgnoreCase() {
+			if !strings.Contains(strings.ToLower(txt), strings.ToLower(sub)) {
+				return false
+			}
+		} else if !strings.Contains(txt, sub) {
+			return false
+		}
+	}
+	return f.re == nil || f.re.MatchString(txt)
+}
+
+// attrsMatch returns true if the msg satisfies all attr filters of the query
+func attrsMatch(msg *cmd.Msg, q *cmd.QueryParams) bool {
+	for _, f := range q.GetAttrs() {
+		a, ok := msg.GetAttrs()[f.GetKey()]
+		if !ok {
+			return false
+		}
+		if f.Eq != nil && !attrEqual(a, f.Eq) {
+			return false
+		}
+		if f.Min == nil && f.Max == nil {
+			continue
+		}
+		if ai, ok := a.GetValue().(*cmd.Attr_Int); ok {
+			if (f.Min != nil && cmpIntFloat(ai.Int, *f.Min) < 0) || (f.Max != nil && cmpIntFloat(ai.Int, *f.Max) > 0) {
+				return false
+			}
+			continue
+		}
+		n, ok := attrNumber(a)
+		if !ok || (f.Min != nil && n < *f.Min) || (f.Max != nil && n > *f.Max) {
+			return false
+		}
+	}
+	return true
+}
+
+// attrEqual compares ints exactly, also with a float,
+// and other numbers by value, else as attrs
+func attrEqual(a, b *cmd.Attr) bool {
+	ai, aok := a.GetValue().(*cmd.Attr_Int)
+	bi, bok := b.GetValue().(*cmd.Attr_Int)
+	if aok && bok {
+		return ai.Int == bi.Int
+	}
+	if bf, ok := b.GetValue().(*cmd.Attr_Float); aok && ok {
+		return cmpIntFloat(ai.Int, bf.Float) == 0
+	}
+	if af, ok := a.GetValue().(*cmd.Attr_Float); bok && ok {
+		return cmpIntFloat(bi.Int, af.Float) == 0
+	}
+	an, aok := attrNumber(a)
+	bn, bok := attrNumber(b)
+	if aok || bok {
+		return aok && bok && an == bn
+	}
+	return proto.Equal(a, b)
+}
+
+// cmpIntFloat compares i with f exactly, without converting
+// i to float64, which would round ints above 2^53. A NaN
+// f compares as equal, so as not to exclude by a bad bound.
+func cmpIntFloat(i int64, f float64) int {
+	switch {
+	case math.IsNaN(f):
+		return 0
+	case f >= math.MaxInt64: // 2^63, as rounded
+		return -1
+	case f < math.MinInt64:
+		return 1
+	}
+	fi := int64(math.Floor(f)) // exact, as f is within range
+	switch {
+	case i < fi:
+		return -1
+	case i > fi:
+		return 1
+	case float64(fi) < f:
+		return -1
+	}
+	return 0
+}
+
+func attrNumber(a *cmd.Attr) (float64, bool) {
+	switch v := a.GetValue().(type) {
+	case *cmd.Attr_Int:
+		return float64(v.Int), true
+	case *cmd.Attr_Float:
+		return v.Float, true
+	}
+	return 0, false
+}
@@ -0,0 +1,85 @@
+package udp
+
+import (
+	"testing"
+
+	"github.com/intob/logd/cmd"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTxtMatches(t *testing.T) {
+	msg := &cmd.Msg{Key: "/test/app", Txt: "read tcp: Connection reset by peer"}
+	cases := []struct {
+		q    *cmd.QueryParams
+		want bool
+	}{
+		{&cmd.QueryParams{}, true},
+		{&cmd.QueryParams{Txt: proto.String("Connection reset")}, true},
+		{&cmd.QueryParams{Txt: proto.String("connection reset")}, false},
+		{&cmd.QueryParams{Txt: proto.String("connection reset"), TxtIgnoreCase: proto.Bool(true)}, true},
+		{&cmd.QueryParams{TxtRegex: proto.String(`^read \w+:`)}, true},
+		{&cmd.QueryParams{TxtRegex: proto.String(`RESET`)}, false},
+		{&cmd.QueryParams{TxtRegex: proto.String(`RESET`), TxtIgnoreCase: proto.Bool(true)}, true},
+		{&cmd.QueryParams{Txt: proto.String("reset"), TxtRegex: proto.String(`^write`)}, false},
+	}
+	for i, c := range cases {
+		f := mustFilter(t, c.q)
+		require.Equal(t, c.want, msgMatchesQuery(msg, f), i)
+		require.Equal(t, c.want, shouldSendToTail(&tail{filter: f}, msg), i)
+	}
+}
+
+func TestNewFilter(t *testing.T) {
+	f := mustFilter(t, &cmd.QueryParams{})
+	require.Nil(t, f.re)
+	f = mustFilter(t, &cmd.QueryParams{TxtRegex: proto.String(`reset$`), TxtIgnoreCase: proto.Bool(true)})
+	require.Equal(t, `(?i)reset$`, f.re.String())
+	_, err := newFilter(&cmd.QueryParams{TxtRegex: proto.String(`(`)})
+	require.Error(t, err)
+}
+
+func mustFilter(t *testing.T, q *cmd.QueryParams) *filter {
+	t.Helper()
+	f, err := newFilter(q)
+	require.NoError(t, err)
+	return f
+}
+
+func TestAttrsMatch(t *testing.T) {
+	msg := &cmd.Msg{Key: "/test/app", Attrs: map[string]*cmd.Attr{
+		"user":   {Value: &cmd.Attr_Str{Str: "joey"}},
+		"status": {Value: &cmd.Attr_Int{Int: 503}},
+		"dur":    {Value: &cmd.Attr_Float{Float: 1.5}},
+		"cached": {Value: &cmd.Attr_Bool{Bool: false}},
+		"big":    {Value: &cmd.Attr_Int{Int: 1<<53 + 1}},
+	}}
+	str := func(s string) *cmd.Attr { return &cmd.Attr{Value: &cmd.Attr_Str{Str: s}} }
+	cases := []struct {
+		filters []*cmd.AttrFilter
+		want    bool
+	}{
+		{nil, true},
+		{[]*cmd.AttrFilter{{Key: "user", Eq: str("joey")}}, true},
+		{[]*cmd.AttrFilter{{Key: "user", Eq: str("jane")}}, false},
+		{[]*cmd.AttrFilter{{Key: "missing"}}, false},
+		{[]*cmd.AttrFilter{{Key: "status", Eq: &cmd.Attr{Value: &cmd.Attr_Float{Float: 503}}}}, true},
+		{[]*cmd.AttrFilter{{Key: "big", Eq: &cmd.Attr{Value: &cmd.Attr_Int{Int: 1<<53 + 1}}}}, true},
+		{[]*cmd.AttrFilter{{Key: "big", Eq: &cmd.Attr{Value: &cmd.Attr_Int{Int: 1 << 53}}}}, false},
+		{[]*cmd.AttrFilter{{Key: "status", Min: proto.Float64(500), Max: proto.Float64(599)}}, true},
+		{[]*cmd.AttrFilter{{Key: "big", Max: proto.Float64(1 << 53)}}, false},
+		{[]*cmd.AttrFilter{{Key: "big", Min: proto.Float64(1 << 53), Max: proto.Float64(1<<53 + 2)}}, true},
+		{[]*cmd.AttrFilter{{Key: "big", Eq: &cmd.Attr{Value: &cmd.Attr_Float{Float: 1 << 53}}}}, false},
+		{[]*cmd.AttrFilter{{Key: "status", Min: proto.Float64(502.5), Max: proto.Float64(503.5)}}, true},
+		{[]*cmd.AttrFilter{{Key: "status", Min: proto.Float64(503.5)}}, false},
+		{[]*cmd.AttrFilter{{Key: "dur", Min: proto.Float64(2)}}, false},
+		{[]*cmd.AttrFilter{{Key: "user", Min: proto.Float64(0)}}, false},
+		{[]*cmd.AttrFilter{{Key: "cached", Eq: &cmd.Attr{Value: &cmd.Attr_Bool{Bool: false}}}}, true},
+		{[]*cmd.AttrFilter{{Key: "user", Eq: str("joey")}, {Key: "dur", Max: proto.Float64(1)}}, false},
+	}
+	for i, c := range cases {
+		f := mustFilter(t, &cmd.QueryParams{Attrs: c.filters})
+		require.Equal(t, c.want, msgMatchesQuery(msg, f), i)
+		require.Equal(t, c.want, shouldSendToTail(&tail{filter: f}, msg), i)
+	}
+}
@@ -0,0 +1,110 @@
+package udp
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/pkg"
+)
+
+const (
+	MaxFragments           = 1024
+	defaultFragmentTimeout = 5 * time.Second
+	defaultFragmentBytes   = 16 << 20
+)
+
+// reassembly buffers fragments until each payload is complete.
+// It is bounded in bytes, and fragments expire after timeout.
+// It must only be used by one goroutine.
+type reassembly struct {
+	pending   map[fragmentKey]*partial
+	bytes     int
+	maxBytes  int
+	timeout   time.Duration
+	lastSweep time.Time
+}
+
+// fragmentKey is unique per client, so that ids chosen
+// by one client do not collide with those of another
+type fragmentKey struct {
+	raddr string
+	id    uint64
+}
+
+type partial struct {
+	parts    [][]byte
+	received uint32
+	bytes    int
+	started  time.Time
+}
+
+func newReassembly(maxBytes int, timeout time.Duration) *reassembly {
+	if maxBytes <= 0 {
+		maxBytes = defaultFragmentBytes
+	}
+	if timeout <= 0 {
+		timeout = defaultFragmentTimeout
+	}
+	return &reassembly{
+		pending:  make(map[fragmentKey]*partial),
+		maxBytes: maxBytes,
+		timeout:  timeout,
+	}
+}
+
+// add buffers the fragment, and returns the payload if complete
+func (r *reassembly) add(raddr string, f *cmd.Fragment, now time.Time) ([]byte, error) {
+	if now.Sub(r.lastSweep) > r.timeout/2 {
+		r.sweep(now)
+	}
+	if f.Total == 0 || f.Total > MaxFragments || f.Index >= f.Total {
+		return nil, fmt.Errorf("invalid fragment %d of %d", f.Index, f.Total)
+	}
+	key := fragmentKey{raddr, f.Id}
+	p, ok := r.pending[key]
+	if !ok {
+		p = &partial{parts: make([][]byte, f.Total), started: now}
+		r.pending[key] = p
+	}
+	if uint32(len(p.parts)) != f.Total {
+		r.drop(key, p)
+		return nil, errors.New("fragment total changed")
+	}
+	if p.parts[f.Index] != nil {
+		return nil, nil // duplicate
+	}
+	if r.bytes+len(f.Data) > r.maxBytes || p.bytes+len(f.Data) > pkg.MaxDecompressedSize {
+		r.drop(key, p)
+		return nil, errors.New("reassembly buffer full")
+	}
+	p.parts[f.Index] = append(make([]byte, 0, len(f.Data)), f.Data...)
+	p.received++
+	p.bytes += len(f.Data)
+	r.bytes += len(f.Data)
+	if p.received < f.Total {
+		return nil, nil
+	}
+	r.drop(key, p)
+	payload := make([]byte, 0, p.bytes)
+	for _, part := range p.parts {
+		payload = append(payload, part...)
+	}
+	return payload, nil
+}
+
+func (r *reassembly) drop(key fragmentKey, p *partial) {
+	r.bytes -= p.bytes
+	delete(r.pending, key)
+}
+
+// sweep drops payloads not completed within timeout
+func (r *reassembly) sweep(now time.Time) {
+	r.lastSweep = now
+	for key, p := range r.pending {
+		if now.Sub(p.started) > r.timeout {
+			r.drop(key, p)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package udp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReassembly(t *testing.T) {
+	r := newReassembly(100, time.Second)
+	now := time.Now()
+	frag := func(id uint64, index, total uint32, data string) *cmd.Fragment {
+		return &cmd.Fragment{Id: id, Index: index, Total: total, Data: []byte(data)}
+	}
+	// out of order, with a duplicate, and another client using the same id
+	payload, err := r.add("a", frag(1, 2, 3, "ghi"), now)
+	require.NoError(t, err)
+	require.Nil(t, payload)
+	_, err = r.add("b", frag(1, 0, 2, "xyz"), now)
+	require.NoError(t, err)
+	_, err = r.add("a", frag(1, 0, 3, "abc"), now)
+	require.NoError(t, err)
+	payload, err = r.add("a", frag(1, 0, 3, "abc"), now)
+	require.NoError(t, err)
+	require.Nil(t, payload)
+	payload, err = r.add("a", frag(1, 1, 3, "def"), now)
+	require.NoError(t, err)
+	require.Equal(t, "abcdefghi", string(payload))
+	require.Equal(t, 3, r.bytes) // b is still pending
+
+	// incomplete payloads expire
+	r.add("a", frag(2, 0, 2, "abc"), now)
+	payload, err = r.add("a", frag(2, 1, 2, "def"), now.Add(2*time.Second))
+	require.NoError(t, err)
+	require.Nil(t, payload)
+	require.Equal(t, uint32(1), r.pending[fragmentKey{"a", 2}].received)
+	require.Equal(t, 3, r.bytes)
+
+	_, err = r.add("a", frag(3, 0, 2, string(make([]byte, 101))), now)
+	require.Error(t, err)
+	_, err = r.add("a", frag(4, 2, 2, "abc"), now)
+	require.Error(t, err)
+	_, err = r.add("a", frag(5, 0, MaxFragments+1, "abc"), now)
+	require.Error(t, err)
+}
@@ -0,0 +1,129 @@
+package udp
+
+import (
+	"net/netip"
+	"sync/atomic"
+	"time"
+)
+
+// queryBounds are the upper bounds of the query latency buckets, in seconds
+var queryBounds = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics is a snapshot of the counters of the svc
+type Metrics struct {
+	BytesIn       uint64
+	BytesOut      uint64
+	Rejected      map[string]uint64     // packets, by reason
+	Tails         int64                 // over udp
+	Subscriptions int64                 // tails over http
+	TailDrops     map[string]uint64     // msgs dropped by tails behind, by transport
+	Queries       map[string]*Histogram // latency, by transport
+	FilterLen     uint64                // sums in the guard filter
+	FilterCap     uint64
+}
+
+// Histogram counts observations in cumulative buckets
+type Histogram struct {
+	Bounds []float64 // upper bounds, excluding +Inf
+	Counts []uint64  // at or below each bound
+	Count  uint64
+	Sum    float64
+}
+
+type histogram struct {
+	counts   []atomic.Uint64 // per bucket, the last being +Inf
+	sumNanos atomic.Uint64
+}
+
+type metrics struct {
+	bytesIn       atomic.Uint64
+	bytesOut      atomic.Uint64
+	unpackFails   atomic.Uint64
+	wrongSecret   atomic.Uint64 // signed, but not by the secret the cmd needs
+	tails         atomic.Int64
+	subscriptions atomic.Int64
+	tailDrops     atomic.Uint64
+	subDrops      atomic.Uint64
+	queries       map[string]*histogram // by transport, fixed at start
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		queries: map[string]*histogram{
+			"udp":  newHistogram(),
+			"http": newHistogram(),
+		},
+	}
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]atomic.Uint64, len(queryBounds)+1)}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(queryBounds) && d.Seconds() > queryBounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+	h.sumNanos.Add(uint64(d))
+}
+
+func (h *histogram) snapshot() *Histogram {
+	s := &Histogram{
+		Bounds: queryBounds,
+		Counts: make([]uint64, len(queryBounds)),
+		Sum:    time.Duration(h.sumNanos.Load()).Seconds(),
+	}
+	for i := range h.counts {
+		s.Count += h.counts[i].Load()
+		if i < len(s.Counts) {
+			s.Counts[i] = s.Count
+		}
+	}
+	return s
+}
+
+// ObserveQuery records the latency of a query over transport, udp or http
+func (svc *UdpSvc) ObserveQuery(transport string, d time.Duration) {
+	if h, ok := svc.metrics.queries[transport]; ok {
+		h.observe(d)
+	}
+}
+
+// Metrics returns a snapshot of the counters
+func (svc *UdpSvc) Metrics() *Metrics {
+	m := &Metrics{
+		BytesIn:       svc.metrics.bytesIn.Load(),
+		BytesOut:      svc.metrics.bytesOut.Load(),
+		Tails:         svc.metrics.tails.Load(),
+		Subscriptions: svc.metrics.subscriptions.Load(),
+		Rejected: map[string]uint64{
+			"unpack":    svc.metrics.unpackFails.Load(),
+			"signature": svc.metrics.wrongSecret.Load(),
+		},
+		TailDrops: map[string]uint64{
+			"udp":  svc.metrics.tailDrops.Load(),
+			"http": svc.metrics.subDrops.Load(),
+		},
+		Queries: make(map[string]*Histogram, len(svc.metrics.queries)),
+	}
+	for transport, h := range svc.metrics.queries {
+		m.Queries[transport] = h.snapshot()
+	}
+	if svc.guard != nil {
+		r := svc.guard.Rejections()
+		m.Rejected["signature"] += r.Signature
+		m.Rejected["stale"] = r.Stale
+		m.Rejected["replay"] = r.Replay
+		m.FilterLen, m.FilterCap = svc.guard.Occupancy()
+	}
+	return m
+}
+
+// writeTo writes b to raddr, counting the bytes sent
+func (svc *UdpSvc) writeTo(b []byte, raddr netip.AddrPort) error {
+	n, err := svc.conn.WriteToUDPAddrPort(b, raddr)
+	svc.metrics.bytesOut.Add(uint64(n))
+	return err
+}
@@ -0,0 +1,24 @@
+package udp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram()
+	h.observe(500 * time.Microsecond)
+	h.observe(time.Millisecond)
+	h.observe(30 * time.Millisecond)
+	h.observe(time.Minute)
+	s := h.snapshot()
+	require.Equal(t, uint64(4), s.Count)
+	require.InDelta(t, 60.0315, s.Sum, 1e-9)
+	require.Len(t, s.Counts, len(queryBounds))
+	require.Equal(t, uint64(2), s.Counts[0])  // 1ms
+	require.Equal(t, uint64(2), s.Counts[3])  // 25ms
+	require.Equal(t, uint64(3), s.Counts[4])  // 50ms
+	require.Equal(t, uint64(3), s.Counts[11]) // 10s, +Inf is Count
+}
@@ -0,0 +1,141 @@
+package udp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/store"
+	"google.golang.org/protobuf/proto"
+)
+
+// startTail starts the sender of the tail, replaying first the msgs since
+// its cursor or tStart, if set. Until the replay has caught up, the msgs
+// written are not queued, but replayed from the store, so that a long
+// replay can't overflow the queue, and none are missed or sent twice.
+func (svc *UdpSvc) startTail(t *tail) {
+	q := t.queryParams
+	if q.GetCursor() == nil && q.GetTStart() == nil {
+		go svc.send(t, nil, svc.goLive(t))
+		return
+	}
+	heads := svc.logStore.Heads()
+	cursor := make(store.Cursor)
+	if q.GetCursor() != nil {
+		cursor, _ = store.ParseCursor(q.GetCursor()) // parsed before
+		if q.GetTStart() == nil {
+			// rings not yet in the cursor had nothing to resume
+			for ring, next := range heads {
+				if _, ok := cursor[ring]; !ok {
+					cursor[ring] = next
+				}
+			}
+		}
+	}
+	query := &store.Query{
+		KeyPrefix:   q.GetKeyPrefix(),
+		Limit:       svc.queryHardLimit,
+		OldestFirst: true,
+		Cursor:      cursor,
+		Txt:         q.GetTxt(),
+		Match: func(msg *cmd.Msg) bool {
+			return tailMatches(t.filter, msg)
+		},
+	}
+	if tStart := tStart(q); tStart != nil {
+		query.Since = *tStart
+		query.Match = func(msg *cmd.Msg) bool {
+			return !msg.T.AsTime().Before(*tStart) && tailMatches(t.filter, msg)
+		}
+	}
+	go svc.send(t, query, heads)
+}
+
+// goLive queues for the tail the msgs written from now on,
+// returning the heads of the rings, which the queue starts at
+func (svc *UdpSvc) goLive(t *tail) store.Cursor {
+	svc.liveMu.Lock()
+	defer svc.liveMu.Unlock()
+	t.live = true
+	return svc.logStore.Heads()
+}
+
+// replay sends the msgs of the query to the tail, up to the heads, then
+// up to the heads since, until a pass replays few enough that those
+// written during the next would fit the queue. Then it takes the tail
+// live, and replays up to the heads it went live at, which the tail
+// continues from. It stops early if the tail is removed.
+func (svc *UdpSvc) replay(t *tail, query *store.Query, heads store.Cursor) {
+	var n uint32
+	for live := false; ; {
+		pass, err := svc.replayTo(t, query, heads)
+		n += pass
+		if err != nil {
+			fmt.Printf("err replaying to %s: %v\n", t.raddr, err)
+			return
+		}
+		if live {
+			break
+		}
+		if pass < tailQueueSize/2 {
+			heads, live = svc.goLive(t), true
+		} else {
+			heads = svc.logStore.Heads()
+		}
+	}
+	svc.replyTail(t, fmt.Sprintf("\rreplayed %d msgs, tailing logs\033[0K", n), heads)
+}
+
+// replayTo sends the msgs of the query up to heads, in pages of up to the
+// query hard limit, moving the cursor of the query past those sent
+func (svc *UdpSvc) replayTo(t *tail, query *store.Query, heads store.Cursor) (uint32, error) {
+	query.End = heads
+	var n uint32
+	for {
+		res := svc.logStore.Read(query)
+		page, err := svc.replayPage(t, res)
+		n += page
+		if err != nil {
+			return n, err
+		}
+		query.Cursor = res.Cursor()
+		if page == 0 || page < query.Limit {
+			return n, nil
+		}
+	}
+}
+
+// replayPage sends the results to the tail, in batches
+// that fit a reply, returning the number of msgs read
+func (svc *UdpSvc) replayPage(t *tail, res *store.Result) (uint32, error) {
+	limit := svc.packetBufferSize
+	if t.queryParams.GetCompression() != cmd.Compression_NONE {
+		limit *= maxReplyRatio
+	}
+	batch, size := make([]*store.Entry, 0), 0
+	var n uint32
+	var err error
+	for e := range res.Entries {
+		n++
+		if err != nil {
+			continue // release the store
+		}
+		select {
+		case <-t.stop:
+			err = errors.New("tail removed")
+			continue
+		default:
+		}
+		batch = append(batch, e)
+		size += proto.Size(e.Msg)
+		if size < limit {
+			continue
+		}
+		err = svc.sendTailEntries(t, batch, 0)
+		batch, size = batch[:0], 0
+	}
+	if err == nil && len(batch) > 0 {
+		err = svc.sendTailEntries(t, batch, 0)
+	}
+	return n, err
+}
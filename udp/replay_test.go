@@ -0,0 +1,218 @@
+package udp
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/pkg"
+	"github.com/intob/logd/store"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestTailResumesWithoutGaps(t *testing.T) {
+	s, err := store.NewStore(&store.Cfg{FallbackSize: 1000})
+	require.NoError(t, err)
+	defer s.Close()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer client.Close()
+	svc := &UdpSvc{
+		conn:             conn,
+		packetBufferSize: 1460,
+		queryHardLimit:   1000,
+		logStore:         s,
+		tails:            make(map[requestKey]*tail),
+		metrics:          newMetrics(),
+	}
+	raddr := client.LocalAddr().(*net.UDPAddr).AddrPort()
+	write := func(i int) {
+		require.NoError(t, svc.handleWrite(&cmd.Msg{Key: "/test/app", Txt: fmt.Sprintf("%d", i)}))
+	}
+	cursor := make(map[string]uint64)
+	// read returns the txts of n msgs, keeping the cursor
+	read := func(n int) []string {
+		txts := make([]string, 0)
+		buf := make([]byte, 1460)
+		for len(txts) < n {
+			require.NoError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+			m, err := client.Read(buf)
+			require.NoError(t, err)
+			raw, err := pkg.Unpacked(buf[:m])
+			require.NoError(t, err)
+			reply := &cmd.Reply{}
+			require.NoError(t, proto.Unmarshal(raw, reply))
+			require.Equal(t, uint64(3), reply.Id)
+			for ring, next := range reply.GetCursor().GetSeqs() {
+				cursor[ring] = max(cursor[ring], next)
+			}
+			for _, msg := range reply.Msgs {
+				if msg.Key != ReplyKey {
+					txts = append(txts, msg.Txt)
+				}
+			}
+		}
+		return txts
+	}
+
+	write(0) // before the tail
+	first := newTail(raddr, 3, mustFilter(t, &cmd.QueryParams{}))
+	svc.tails[requestKey{raddr, 3}] = first
+	svc.startTail(first)
+	write(1)
+	write(2)
+	require.Equal(t, []string{"1", "2"}, read(2))
+
+	// kicked, the tail misses msgs until resumed
+	delete(svc.tails, requestKey{raddr, 3})
+	close(first.stop)
+	write(3)
+	write(4)
+	c, err := proto.Marshal(&cmd.Cursor{Seqs: cursor})
+	require.NoError(t, err)
+	resumed := newTail(raddr, 3, mustFilter(t, &cmd.QueryParams{Cursor: c}))
+	svc.tails[requestKey{raddr, 3}] = resumed
+	defer close(resumed.stop)
+	svc.startTail(resumed)
+	write(5) // queued behind the replay
+	require.Equal(t, []string{"3", "4", "5"}, read(3))
+	write(6)
+	require.Equal(t, []string{"6"}, read(1))
+}
+
+func TestSlowTailDrops(t *testing.T) {
+	s, err := store.NewStore(&store.Cfg{FallbackSize: 2000})
+	require.NoError(t, err)
+	defer s.Close()
+	svc := &UdpSvc{logStore: s, tails: make(map[requestKey]*tail), metrics: newMetrics()}
+	// no sender, as if stuck, so the queue fills
+	stuck := newTail(netip.MustParseAddrPort("127.0.0.1:9"), 1, mustFilter(t, &cmd.QueryParams{}))
+	stuck.live = true
+	svc.tails[requestKey{stuck.raddr, stuck.id}] = stuck
+	for i := 0; i < tailQueueSize+10; i++ {
+		require.NoError(t, svc.handleWrite(&cmd.Msg{Key: "/test/app", Txt: fmt.Sprintf("%d", i)}))
+	}
+	require.Equal(t, uint64(10), stuck.dropped)
+	require.Equal(t, uint64(10), svc.Metrics().TailDrops["udp"])
+	<-stuck.queue
+	require.True(t, stuck.enqueue(&store.Entry{}))
+	var ev *tailEvent
+	for len(stuck.queue) > 0 {
+		ev = <-stuck.queue
+	}
+	require.Equal(t, uint64(10), ev.dropped)
+	require.Zero(t, stuck.dropped)
+}
+
+// replayTail writes before msgs, then tails from their start, writing
+// during msgs as the replay runs, and returns the txts of the msgs
+// received, the txt of the reply ending the replay & the msgs dropped
+func replayTail(t *testing.T, queryHardLimit uint32, before, during int) ([]string, string, uint64) {
+	s, err := store.NewStore(&store.Cfg{FallbackSize: uint32(before + during)})
+	require.NoError(t, err)
+	defer s.Close()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer client.Close()
+	require.NoError(t, client.SetReadBuffer(4<<20))
+	svc := &UdpSvc{
+		conn:             conn,
+		packetBufferSize: 1460,
+		queryHardLimit:   queryHardLimit,
+		logStore:         s,
+		tails:            make(map[requestKey]*tail),
+		metrics:          newMetrics(),
+	}
+	raddr := client.LocalAddr().(*net.UDPAddr).AddrPort()
+	write := func(i int) {
+		require.NoError(t, svc.handleWrite(&cmd.Msg{Key: "/test/app", Txt: fmt.Sprintf("%d", i), T: timestamppb.Now()}))
+	}
+	tStart := time.Now()
+	for i := 0; i < before; i++ {
+		write(i)
+	}
+	// read as the replay runs, so that the socket buffer can't overflow
+	replies := make(chan *cmd.Reply, 1024)
+	go func() {
+		defer close(replies)
+		buf := make([]byte, 1460)
+		for {
+			client.SetReadDeadline(time.Now().Add(time.Second))
+			m, err := client.Read(buf)
+			if err != nil {
+				return
+			}
+			raw, err := pkg.Unpacked(buf[:m])
+			if err != nil {
+				return
+			}
+			reply := &cmd.Reply{}
+			if proto.Unmarshal(raw, reply) == nil {
+				replies <- reply
+			}
+		}
+	}()
+	tail := newTail(raddr, 3, mustFilter(t, &cmd.QueryParams{TStart: timestamppb.New(tStart)}))
+	svc.tails[requestKey{raddr, 3}] = tail
+	defer close(tail.stop)
+	svc.startTail(tail)
+	for i := before; i < before+during; i++ {
+		write(i)
+	}
+
+	txts := make([]string, 0)
+	replayed := ""
+	var dropped uint64
+	for len(txts) < before+during || replayed == "" {
+		reply, ok := <-replies
+		require.True(t, ok, "received %d of %d msgs", len(txts), before+during)
+		dropped += reply.Dropped
+		for _, msg := range reply.Msgs {
+			if msg.Key == ReplyKey {
+				replayed = msg.Txt
+				continue
+			}
+			txts = append(txts, msg.Txt)
+		}
+	}
+	return txts, replayed, dropped
+}
+
+func wantTxts(n int) []string {
+	txts := make([]string, n)
+	for i := range txts {
+		txts[i] = fmt.Sprintf("%d", i)
+	}
+	return txts
+}
+
+func TestTailReplaysPastTheLimit(t *testing.T) {
+	txts, replayed, _ := replayTail(t, 3, 10, 1)
+	require.Equal(t, wantTxts(11), txts)
+	require.Contains(t, replayed, "replayed")
+}
+
+func TestLongReplayDropsNone(t *testing.T) {
+	// more written during the replay than the queue holds
+	txts, _, dropped := replayTail(t, 10, 1000, tailQueueSize+100)
+	require.Equal(t, wantTxts(1000+tailQueueSize+100), txts)
+	require.Zero(t, dropped)
+}
+
+func TestReplayWithoutLimit(t *testing.T) {
+	// a hard limit of 0 reads nothing, so the replay must end
+	txts, replayed, _ := replayTail(t, 0, 0, 0)
+	require.Empty(t, txts)
+	require.Contains(t, replayed, "replayed 0 msgs")
+}
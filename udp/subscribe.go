@@ -0,0 +1,58 @@
+package udp
+
+import (
+	"github.com/intob/logd/cmd"
+)
+
+const subscriptionBuffer = 256
+
+// Subscription receives the msgs written that match its
+// query params, as a udp tail would. A subscriber that
+// falls behind misses msgs, rather than blocking writes.
+type Subscription struct {
+	C       <-chan *Event
+	c       chan *Event
+	filter  *filter
+	dropped uint64 // since last event, used only by theThing
+}
+
+// Event is a msg written, and the number of
+// msgs dropped before it, if the subscriber fell behind
+type Event struct {
+	Msg     *cmd.Msg
+	Dropped uint64
+}
+
+// Subscribe returns a subscription to msgs matching the query
+// params. It must be cancelled by Unsubscribe.
+func (svc *UdpSvc) Subscribe(queryParams *cmd.QueryParams) (*Subscription, error) {
+	f, err := newFilter(queryParams)
+	if err != nil {
+		return nil, err
+	}
+	c := make(chan *Event, subscriptionBuffer)
+	sub := &Subscription{C: c, c: c, filter: f}
+	svc.subscribe <- sub
+	return sub, nil
+}
+
+// Unsubscribe stops the subscription, and closes its channel
+func (svc *UdpSvc) Unsubscribe(sub *Subscription) {
+	svc.unsubscribe <- sub
+}
+
+// publish sends msg to each matching subscriber without blocking
+func (svc *UdpSvc) publish(msg *cmd.Msg) {
+	for sub := range svc.subscriptions {
+		if !tailMatches(sub.filter, msg) {
+			continue
+		}
+		select {
+		case sub.c <- &Event{Msg: msg, Dropped: sub.dropped}:
+			sub.dropped = 0
+		default:
+			sub.dropped++
+			svc.metrics.subDrops.Add(1)
+		}
+	}
+}
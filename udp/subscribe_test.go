@@ -0,0 +1,27 @@
+package udp
+
+import (
+	"testing"
+
+	"github.com/intob/logd/cmd"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestPublishDropsWithGap(t *testing.T) {
+	c := make(chan *Event, 2)
+	sub := &Subscription{C: c, c: c, filter: mustFilter(t, &cmd.QueryParams{KeyPrefix: proto.String("/a")})}
+	svc := &UdpSvc{subscriptions: map[*Subscription]struct{}{sub: {}}, metrics: newMetrics()}
+	for _, key := range []string{"/a/1", "/b/2", "/a/3", "/a/4", "/a/5"} {
+		svc.publish(&cmd.Msg{Key: key})
+	}
+	require.Equal(t, uint64(2), sub.dropped)
+	require.Equal(t, uint64(2), svc.metrics.subDrops.Load())
+	require.Equal(t, "/a/1", (<-sub.C).Msg.Key)
+	require.Equal(t, "/a/3", (<-sub.C).Msg.Key)
+	svc.publish(&cmd.Msg{Key: "/a/6"})
+	ev := <-sub.C
+	require.Equal(t, "/a/6", ev.Msg.Key)
+	require.Equal(t, uint64(2), ev.Dropped)
+	require.Zero(t, sub.dropped)
+}
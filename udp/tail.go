@@ -0,0 +1,181 @@
+package udp
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/pkg"
+	"github.com/intob/logd/store"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	tailQueueSize = 1024 // msgs queued per tail, after which they are dropped
+	tailBatch     = 64   // msgs sent together, if queued
+)
+
+// tail is sent the msgs written that match its query params. Each tail
+// has its own queue & sender, so that a slow one can't stall writes.
+type tail struct {
+	raddr       netip.AddrPort
+	id          uint64 // of the TAIL cmd, echoed in replies
+	lastPing    time.Time
+	queryParams *cmd.QueryParams
+	filter      *filter // of queryParams
+	queue       chan *tailEvent
+	stop        chan struct{} // closed once removed
+	kicked      bool          // set before stop is closed
+	dropped     uint64        // since last queued, used only by theThing
+	live        bool          // queued the msgs written, guarded by liveMu
+}
+
+// tailEvent is a msg written, and the number of
+// msgs dropped before it, as the queue was full
+type tailEvent struct {
+	entry   *store.Entry
+	dropped uint64
+}
+
+func newTail(raddr netip.AddrPort, id uint64, f *filter) *tail {
+	return &tail{
+		raddr:       raddr,
+		id:          id,
+		lastPing:    time.Now(),
+		queryParams: f.q,
+		filter:      f,
+		queue:       make(chan *tailEvent, tailQueueSize),
+		stop:        make(chan struct{}),
+	}
+}
+
+// enqueue queues entry for the sender without blocking,
+// returning false if dropped as the queue is full
+func (t *tail) enqueue(entry *store.Entry) bool {
+	select {
+	case t.queue <- &tailEvent{entry: entry, dropped: t.dropped}:
+		t.dropped = 0
+		return true
+	default:
+		t.dropped++
+		return false
+	}
+}
+
+// send replays to the tail if asked, then sends the
+// msgs queued, in batches, until the tail is removed
+func (svc *UdpSvc) send(t *tail, replay *store.Query, heads store.Cursor) {
+	if replay != nil {
+		svc.replay(t, replay, heads)
+	} else {
+		svc.replyTail(t, "\rtailing logs\033[0K", heads)
+	}
+	entries := make([]*store.Entry, 0, tailBatch)
+	for {
+		select {
+		case <-t.stop:
+			if t.kicked {
+				svc.reply(KickMsg, t.raddr, t.id)
+			}
+			return
+		default:
+		}
+		var ev *tailEvent
+		select {
+		case <-t.stop:
+			continue
+		case ev = <-t.queue:
+		}
+		entries = append(entries[:0], ev.entry)
+		dropped := ev.dropped
+	batch:
+		for len(entries) < tailBatch {
+			select {
+			case ev = <-t.queue:
+				entries = append(entries, ev.entry)
+				dropped += ev.dropped
+			default:
+				break batch
+			}
+		}
+		err := svc.sendTailEntries(t, entries, dropped)
+		if err != nil {
+			fmt.Printf("err writing to %s: %v\n", t.raddr, err)
+		}
+	}
+}
+
+// sendTailEntries sends the msgs of entries to the tail. Tails with an
+// id or compression get replies, each carrying the cursor after its
+// msgs, the batch being halved until each fits the packet buffer size.
+// Others are told of msgs dropped before the msgs are sent.
+func (svc *UdpSvc) sendTailEntries(t *tail, entries []*store.Entry, dropped uint64) error {
+	c := t.queryParams.GetCompression()
+	if c == cmd.Compression_NONE && t.id == 0 {
+		if dropped > 0 {
+			svc.reply(fmt.Sprintf("dropped %d msgs, as the tail fell behind", dropped), t.raddr, 0)
+		}
+		for _, e := range entries {
+			err := svc.writeTo(e.Data, t.raddr)
+			if err != nil {
+				return fmt.Errorf("err writing to conn: %w", err)
+			}
+		}
+		return nil
+	}
+	reply := &cmd.Reply{Id: t.id, Dropped: dropped, Cursor: &cmd.Cursor{Seqs: make(map[string]uint64)}}
+	for _, e := range entries {
+		reply.Msgs = append(reply.Msgs, e.Msg)
+		reply.Cursor.Seqs[e.Ring] = max(reply.Cursor.Seqs[e.Ring], e.Seq+1)
+	}
+	raw, err := proto.Marshal(reply)
+	if err != nil {
+		return fmt.Errorf("err marshaling reply: %w", err)
+	}
+	packed, err := pkg.Pack(c, raw)
+	if err != nil {
+		return fmt.Errorf("err compressing reply: %w", err)
+	}
+	if len(packed) > svc.packetBufferSize && len(entries) > 1 {
+		half := len(entries) / 2
+		err = svc.sendTailEntries(t, entries[:half], dropped)
+		if err != nil {
+			return err
+		}
+		return svc.sendTailEntries(t, entries[half:], 0)
+	}
+	if c != cmd.Compression_NONE {
+		svc.compressStats.replyRaw.Add(uint64(len(raw)))
+		svc.compressStats.replyPacked.Add(uint64(len(packed)))
+	}
+	err = svc.writeTo(packed, t.raddr)
+	if err != nil {
+		return fmt.Errorf("err writing to conn: %w", err)
+	}
+	return nil
+}
+
+// replyTail sends txt to the tail, with the cursor if it has an id
+func (svc *UdpSvc) replyTail(t *tail, txt string, cursor store.Cursor) {
+	if t.id == 0 || cursor == nil {
+		svc.reply(txt, t.raddr, t.id)
+		return
+	}
+	fmt.Printf("reply to %s: %q\n", t.raddr, txt)
+	raw, err := proto.Marshal(&cmd.Reply{
+		Id:     t.id,
+		Msgs:   []*cmd.Msg{{Key: ReplyKey, Txt: txt}},
+		Cursor: &cmd.Cursor{Seqs: cursor},
+	})
+	if err == nil {
+		var packed []byte
+		packed, err = pkg.Pack(cmd.Compression_NONE, raw)
+		if err == nil {
+			err = svc.writeTo(packed, t.raddr)
+		}
+	}
+	if err != nil {
+		fmt.Printf("err replying to %s: %v\n", t.raddr, err)
+	}
+}
@@ -0,0 +1,213 @@
+package udp
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/pkg"
+	"github.com/intob/logd/store"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	MaxQueryWindow     = 256
+	retransmitTimeout  = 200 * time.Millisecond
+	maxRetransmits     = 25 // consecutive, before giving up on the client
+	transferAckBacklog = 16
+)
+
+// replyOverhead is the most that the flag, seq & id add to a reply
+const replyOverhead = 1 + 6 + 11
+
+// transfer sends the results of a reliable query as numbered replies,
+// no more than window unacknowledged. Replies not acknowledged within
+// the retransmit timeout are sent again, as are those reported missing.
+// The last reply is the end, carrying the cursor & the total, or the
+// error of a query that failed, which is then the only reply.
+type transfer struct {
+	svc         *UdpSvc
+	raddr       netip.AddrPort
+	id          uint64 // of the QUERY cmd
+	compression cmd.Compression
+	window      uint32
+	res         *store.Result // nil if failed
+	err         error         // of the query, if failed
+	acks        chan *cmd.Ack
+	inFlight    [][]byte // unacknowledged, from base
+	base        uint32   // seq of the oldest unacknowledged reply
+	next        uint32   // seq of the next reply to send
+	pending     []*cmd.Msg
+	drained     bool // results read
+	ended       bool // end sent
+}
+
+// newTransfer returns a transfer of res, or of the
+// error alone, if the query failed with err
+func (svc *UdpSvc) newTransfer(query *cmd.QueryParams, id uint64, res *store.Result, err error, raddr netip.AddrPort) *transfer {
+	return &transfer{
+		svc:         svc,
+		raddr:       raddr,
+		id:          id,
+		compression: query.GetCompression(),
+		window:      min(max(query.GetWindow(), 1), MaxQueryWindow),
+		res:         res,
+		err:         err,
+		drained:     res == nil,
+		acks:        make(chan *cmd.Ack, transferAckBacklog),
+	}
+}
+
+// run sends the replies until all are acknowledged
+func (t *transfer) run() error {
+	if t.res != nil {
+		defer t.res.Drain() // if the client is gone
+	}
+	retransmits := 0
+	for {
+		for !t.ended && t.next-t.base < t.window {
+			reply, err := t.pack()
+			if err != nil {
+				return err
+			}
+			t.inFlight = append(t.inFlight, reply)
+			t.next++
+			err = t.svc.writeTo(reply, t.raddr)
+			if err != nil {
+				return fmt.Errorf("err writing to conn: %w", err)
+			}
+		}
+		if t.ended && t.base == t.next {
+			return nil
+		}
+		select {
+		case ack := <-t.acks:
+			retransmits = 0
+			err := t.ack(ack)
+			if err != nil {
+				return err
+			}
+		case <-time.After(retransmitTimeout):
+			retransmits++
+			if retransmits > maxRetransmits {
+				return errors.New("client stopped acknowledging")
+			}
+			for _, reply := range t.inFlight {
+				err := t.svc.writeTo(reply, t.raddr)
+				if err != nil {
+					return fmt.Errorf("err writing to conn: %w", err)
+				}
+			}
+		}
+	}
+}
+
+// ack slides the window, and sends again the replies reported missing
+func (t *transfer) ack(ack *cmd.Ack) error {
+	if ack.Next > t.base && ack.Next <= t.next {
+		t.inFlight = t.inFlight[ack.Next-t.base:]
+		t.base = ack.Next
+	}
+	for _, seq := range ack.Missing {
+		if seq < t.base || seq >= t.next {
+			continue
+		}
+		err := t.svc.writeTo(t.inFlight[seq-t.base], t.raddr)
+		if err != nil {
+			return fmt.Errorf("err writing to conn: %w", err)
+		}
+	}
+	return nil
+}
+
+// pack returns the next reply, of as many msgs as fit,
+// or the end once the results are all sent
+func (t *transfer) pack() ([]byte, error) {
+	limit := t.svc.packetBufferSize - replyOverhead
+	if t.compression != cmd.Compression_NONE {
+		limit *= maxReplyRatio
+	}
+	batch, size := make([]*cmd.Msg, 0), 0
+	for {
+		msg := t.nextMsg()
+		if msg == nil {
+			break
+		}
+		msgSize := protowire.SizeTag(1) + protowire.SizeBytes(proto.Size(msg))
+		if len(batch) > 0 && size+msgSize > limit {
+			t.pending = append(t.pending, msg)
+			break
+		}
+		batch = append(batch, msg)
+		size += msgSize
+	}
+	if len(batch) == 0 {
+		t.ended = true
+		end := &cmd.Reply{Seq: t.next, Total: proto.Uint32(t.next)}
+		if t.err != nil {
+			end.Err = t.err.Error()
+		} else {
+			end.Msgs = []*cmd.Msg{{
+				Key:    ReplyKey,
+				Txt:    EndMsg,
+				Cursor: t.res.Cursor().Bytes(),
+			}}
+		}
+		return t.marshal(end)
+	}
+	for {
+		reply, err := t.marshal(&cmd.Reply{Seq: t.next, Msgs: batch})
+		if err != nil {
+			return nil, err
+		}
+		// compressed, the msgs may not fit after all
+		if len(reply) <= t.svc.packetBufferSize || len(batch) == 1 {
+			return reply, nil
+		}
+		half := len(batch) / 2
+		for i := len(batch) - 1; i >= half; i-- {
+			t.pending = append(t.pending, batch[i])
+		}
+		batch = batch[:half]
+	}
+}
+
+// nextMsg returns the next msg to send, or nil once all are sent.
+// Msgs put back by pack are taken first, from the top of the stack.
+func (t *transfer) nextMsg() *cmd.Msg {
+	if n := len(t.pending); n > 0 {
+		msg := t.pending[n-1]
+		t.pending = t.pending[:n-1]
+		return msg
+	}
+	if t.drained {
+		return nil
+	}
+	entry, ok := <-t.res.Entries
+	if !ok {
+		t.drained = true
+		return nil
+	}
+	entry.Msg.Seq = entry.Seq
+	return entry.Msg
+}
+
+func (t *transfer) marshal(reply *cmd.Reply) ([]byte, error) {
+	reply.Id = t.id
+	raw, err := proto.Marshal(reply)
+	if err != nil {
+		return nil, fmt.Errorf("err marshaling reply: %w", err)
+	}
+	packed, err := pkg.Pack(t.compression, raw)
+	if err != nil {
+		return nil, fmt.Errorf("err compressing reply: %w", err)
+	}
+	if t.compression != cmd.Compression_NONE {
+		t.svc.compressStats.replyRaw.Add(uint64(len(raw)))
+		t.svc.compressStats.replyPacked.Add(uint64(len(packed)))
+	}
+	return packed, nil
+}
@@ -0,0 +1,122 @@
+package udp
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/pkg"
+	"github.com/intob/logd/store"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestTransferRetransmits(t *testing.T) {
+	s, err := store.NewStore(&store.Cfg{FallbackSize: 1000})
+	require.NoError(t, err)
+	defer s.Close()
+	t0 := time.Now()
+	for i := 0; i < 200; i++ {
+		msg := &cmd.Msg{Key: "/test/app", Txt: fmt.Sprintf("msg %d", i), T: timestamppb.New(t0.Add(time.Duration(i)))}
+		data, err := proto.Marshal(msg)
+		require.NoError(t, err)
+		require.NoError(t, s.Write("/test/app", msg.T.AsTime(), data))
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer client.Close()
+	svc := &UdpSvc{conn: conn, packetBufferSize: 256, metrics: newMetrics()}
+	q := &cmd.QueryParams{Window: proto.Uint32(4), Order: cmd.Order_OLDEST_FIRST.Enum()}
+	res := s.Read(&store.Query{KeyPrefix: "/test/app", Limit: 1000, OldestFirst: true})
+	tr := svc.newTransfer(q, 9, res, nil, client.LocalAddr().(*net.UDPAddr).AddrPort())
+	done := make(chan error)
+	go func() { done <- tr.run() }()
+
+	// lose the first of replies 2 & 5, and report 5 missing
+	lost := map[uint32]bool{2: true, 5: true}
+	received := make(map[uint32]*cmd.Reply)
+	next := uint32(0)
+	buf := make([]byte, 512)
+	for {
+		require.NoError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+		n, err := client.Read(buf)
+		require.NoError(t, err)
+		require.LessOrEqual(t, n, 256)
+		raw, err := pkg.Unpacked(buf[:n])
+		require.NoError(t, err)
+		reply := &cmd.Reply{}
+		require.NoError(t, proto.Unmarshal(raw, reply))
+		require.Equal(t, uint64(9), reply.Id)
+		if lost[reply.Seq] {
+			delete(lost, reply.Seq)
+			continue
+		}
+		received[reply.Seq] = reply
+		for received[next] != nil {
+			next++
+		}
+		ack := &cmd.Ack{Next: next}
+		if next == 5 && received[6] != nil {
+			ack.Missing = []uint32{5}
+		}
+		tr.acks <- ack
+		if end := received[next-1]; next > 0 && end.Total != nil {
+			require.Equal(t, next-1, end.GetTotal())
+			break
+		}
+	}
+	require.NoError(t, <-done)
+	var got []string
+	for seq := uint32(0); seq < next-1; seq++ {
+		for _, m := range received[seq].Msgs {
+			got = append(got, m.Txt)
+		}
+	}
+	require.Len(t, got, 200)
+	for i, txt := range got {
+		require.Equal(t, fmt.Sprintf("msg %d", i), txt)
+	}
+}
+
+func TestQueryErrEndsTransfer(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+	client, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer client.Close()
+	svc := &UdpSvc{conn: conn, packetBufferSize: 256, metrics: newMetrics()}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		svc.handleQuery(&cmd.Cmd{Name: cmd.Name_QUERY, Id: 4, QueryParams: &cmd.QueryParams{
+			Window:   proto.Uint32(4),
+			TxtRegex: proto.String("("),
+		}}, client.LocalAddr().(*net.UDPAddr).AddrPort())
+	}()
+	buf := make([]byte, 256)
+	// sent again until acknowledged
+	for i := 0; i < 2; i++ {
+		require.NoError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+		n, err := client.Read(buf)
+		require.NoError(t, err)
+		raw, err := pkg.Unpacked(buf[:n])
+		require.NoError(t, err)
+		reply := &cmd.Reply{}
+		require.NoError(t, proto.Unmarshal(raw, reply))
+		require.Equal(t, uint64(4), reply.Id)
+		require.Equal(t, uint32(0), reply.GetTotal())
+		require.Contains(t, reply.Err, "regex")
+		require.Empty(t, reply.Msgs)
+	}
+	tr, ok := svc.transfers.Load(requestKey{client.LocalAddr().(*net.UDPAddr).AddrPort(), 4})
+	require.True(t, ok)
+	tr.(*transfer).acks <- &cmd.Ack{Next: 1}
+	<-done
+}
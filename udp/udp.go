@@ -1,7 +1,9 @@
 package udp
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net"
@@ -14,23 +16,29 @@ import (
 	"github.com/intob/logd/guard"
 	"github.com/intob/logd/pkg"
 	"github.com/intob/logd/store"
+	"google.golang.org/protobuf/encoding/protowire"
 	"google.golang.org/protobuf/proto"
 )
 
 const (
 	ReplyKey          = "//logd"
 	EndMsg            = "+END"
+	KickMsg           = "kick" // to a tail not pinged, which may resume
 	PingPeriod        = 2 * time.Second
 	PingLossTolerance = 3
+	seqFieldNum       = protowire.Number(13) // cmd.Msg.seq
 )
 
 type Cfg struct {
-	LaddrPort        string     `yaml:"laddr_port"`
-	PacketBufferSize int        `yaml:"packet_buffer_size"`
-	QueryHardLimit   uint32     `yaml:"query_hard_limit"`
-	Guard            *guard.Cfg `yaml:"guard"`
-	Secrets          *Secrets   `yaml:"secrets"`
-	LogStore         *store.Store
+	LaddrPort          string        `yaml:"laddr_port"`
+	PacketBufferSize   int           `yaml:"packet_buffer_size"`
+	QueryHardLimit     uint32        `yaml:"query_hard_limit"`
+	AggregateHardLimit uint32        `yaml:"aggregate_hard_limit"` // msgs counted
+	Guard              *guard.Cfg    `yaml:"guard"`
+	Secrets            *Secrets      `yaml:"secrets"`
+	FragmentTimeout    time.Duration `yaml:"fragment_timeout"` // drop incomplete msgs after
+	FragmentBytes      int           `yaml:"fragment_bytes"`   // bound of reassembly buffer
+	LogStore           *store.Store
 }
 
 type Secrets struct {
@@ -39,38 +47,54 @@ type Secrets struct {
 }
 
 type UdpSvc struct {
-	laddrPort        string
-	packetBufferSize int
-	queryHardLimit   uint32
-	secrets          *Secrets
-	conn             *net.UDPConn
-	tails            map[string]*tail
-	ping             chan string
-	newTail          chan *tail
-	write            chan *cmd.Msg
-	logStore         *store.Store
-	pkgPool          *sync.Pool
-	guard            *guard.Guard
+	laddrPort          string
+	packetBufferSize   int
+	queryHardLimit     uint32
+	aggregateHardLimit uint32
+	secrets            *Secrets
+	conn               *net.UDPConn
+	tails              map[requestKey]*tail
+	ping               chan requestKey
+	newTail            chan *tail
+	subscriptions      map[*Subscription]struct{}
+	subscribe          chan *Subscription
+	unsubscribe        chan *Subscription
+	write              chan *cmd.Msg
+	logStore           *store.Store
+	pkgPool            *sync.Pool
+	guard              *guard.Guard
+	compressStats      compressStats
+	metrics            *metrics
+	transfers          sync.Map    // of reliable queries, by requestKey
+	liveMu             sync.Mutex  // held to write, and to take a tail live
+	fragments          *reassembly // used only by the listening goroutine
 }
 
-type tail struct {
-	raddr       netip.AddrPort
-	lastPing    time.Time
-	queryParams *cmd.QueryParams
+// requestKey identifies a tail or query, as a client
+// may have many on one socket, distinguished by id
+type requestKey struct {
+	raddr netip.AddrPort
+	id    uint64
 }
 
 func NewSvc(ctx context.Context, cfg *Cfg) *UdpSvc {
 	svc := &UdpSvc{
-		laddrPort:        cfg.LaddrPort,
-		packetBufferSize: cfg.PacketBufferSize,
-		queryHardLimit:   cfg.QueryHardLimit,
-		guard:            guard.NewGuard(ctx, cfg.Guard),
-		tails:            make(map[string]*tail),
-		write:            make(chan *cmd.Msg, 100),
-		ping:             make(chan string, 1),
-		newTail:          make(chan *tail, 1),
-		secrets:          cfg.Secrets,
-		logStore:         cfg.LogStore,
+		laddrPort:          cfg.LaddrPort,
+		packetBufferSize:   cfg.PacketBufferSize,
+		queryHardLimit:     cfg.QueryHardLimit,
+		aggregateHardLimit: cfg.AggregateHardLimit,
+		guard:              guard.NewGuard(ctx, cfg.Guard),
+		tails:              make(map[requestKey]*tail),
+		write:              make(chan *cmd.Msg, 100),
+		ping:               make(chan requestKey, 1),
+		newTail:            make(chan *tail, 1),
+		subscriptions:      make(map[*Subscription]struct{}),
+		subscribe:          make(chan *Subscription),
+		unsubscribe:        make(chan *Subscription),
+		secrets:            cfg.Secrets,
+		logStore:           cfg.LogStore,
+		fragments:          newReassembly(cfg.FragmentBytes, cfg.FragmentTimeout),
+		metrics:            newMetrics(),
 		pkgPool: &sync.Pool{
 			New: func() any {
 				return &pkg.Pkg{
@@ -113,168 +137,411 @@ func (svc *UdpSvc) readPacket() error {
 	if err != nil {
 		return fmt.Errorf("err reading from socket: %w", err)
 	}
+	svc.metrics.bytesIn.Add(uint64(n))
 	// get a pointer to a reusable pkg.Pkg to unpack packet
 	p, _ := svc.pkgPool.Get().(*pkg.Pkg)
 	defer svc.pkgPool.Put(p)
 	err = pkg.Unpack(buf[:n], p)
 	if err != nil {
+		svc.metrics.unpackFails.Add(1)
 		// probably garbage, log once in a while
 		if rand.Intn(100) == 0 {
 			fmt.Printf("~100x err unpacking packet: %v\n", err)
 		}
 		return nil
 	}
+	// verified before the payload is touched, which may be large once
+	// decompressed. The cmd decides which of the secrets it needs.
+	secrets := [][]byte{[]byte(svc.secrets.Write), []byte(svc.secrets.Read)}
+	signer := svc.guard.Signer(p, secrets...)
+	if signer < 0 {
+		return nil
+	}
+	payload, err := svc.decompress(pkg.Flagged(p.Payload))
+	if err != nil {
+		svc.metrics.unpackFails.Add(1)
+		// signed, but malformed, log once in a while
+		if rand.Intn(100) == 0 {
+			fmt.Printf("~100x err decompressing cmd: %v\n", err)
+		}
+		return nil
+	}
 	c := &cmd.Cmd{}
-	err = proto.Unmarshal(p.Payload, c)
+	err = proto.Unmarshal(payload, c)
 	if err != nil {
-		// probably garbage, log once in a while
+		svc.metrics.unpackFails.Add(1)
+		// signed, but malformed, log once in a while
 		if rand.Intn(100) == 0 {
 			fmt.Printf("~100x err unmarshaling cmd: %v\n", err)
 		}
 		return nil
 	}
+	needed := secrets[1]
+	if c.Name == cmd.Name_WRITE {
+		needed = secrets[0]
+	}
+	if !bytes.Equal(secrets[signer], needed) {
+		svc.metrics.wrongSecret.Add(1)
+		fmt.Printf("unauthorised: %s not signed by its secret\n", c.Name)
+		return nil
+	}
 	switch c.Name {
 	case cmd.Name_WRITE:
-		if !svc.guard.Good([]byte(svc.secrets.Write), p) {
-			return nil
+		if c.Fragment != nil {
+			return svc.handleFragment(c.Fragment, raddr)
 		}
-		svc.write <- c.Msg
+		svc.enqueueWrites(c)
 	case cmd.Name_TAIL:
-		if !svc.guard.Good([]byte(svc.secrets.Read), p) {
-			return nil
+		f, err := newFilter(c.GetQueryParams())
+		if err == nil && c.GetQueryParams().GetCursor() != nil {
+			_, err = store.ParseCursor(c.GetQueryParams().GetCursor())
 		}
-		svc.newTail <- &tail{
-			raddr:       raddr,
-			lastPing:    time.Now(),
-			queryParams: c.GetQueryParams(),
-		}
-		svc.reply("\rtailing logs\033[0K", raddr)
-	case cmd.Name_PING:
-		if !svc.guard.Good([]byte(svc.secrets.Read), p) {
+		if err != nil {
+			svc.reply(err.Error(), raddr, c.Id)
 			return nil
 		}
-		svc.ping <- raddr.String()
+		svc.newTail <- newTail(raddr, c.Id, f)
+	case cmd.Name_PING:
+		svc.ping <- requestKey{raddr, c.Id}
 	case cmd.Name_QUERY:
-		if !svc.guard.Good([]byte(svc.secrets.Read), p) {
-			return nil
-		}
 		go svc.handleQuery(c, raddr)
+	case cmd.Name_AGGREGATE:
+		go svc.handleAggregate(c, raddr)
+	case cmd.Name_ACK:
+		if t, ok := svc.transfers.Load(requestKey{raddr, c.Id}); ok {
+			select {
+			case t.(*transfer).acks <- c.GetAck():
+			default: // the transfer is behind, it will retransmit
+			}
+		}
 	}
 	return nil
 }
 
+// handleFragment buffers the fragment of a WRITE, and enqueues
+// the msgs once complete. As each fragment is verified with
+// the write secret, so is the payload.
+func (svc *UdpSvc) handleFragment(f *cmd.Fragment, raddr netip.AddrPort) error {
+	payload, err := svc.fragments.add(raddr.String(), f, time.Now())
+	if err != nil {
+		return fmt.Errorf("err reassembling fragment from %s: %w", raddr, err)
+	}
+	if payload == nil {
+		return nil
+	}
+	payload, err = svc.decompress(f.Compression, payload)
+	if err != nil {
+		return fmt.Errorf("err decompressing reassembled cmd: %w", err)
+	}
+	c := &cmd.Cmd{}
+	err = proto.Unmarshal(payload, c)
+	if err != nil {
+		return fmt.Errorf("err unmarshaling reassembled cmd: %w", err)
+	}
+	if c.Name != cmd.Name_WRITE || c.Fragment != nil {
+		return errors.New("reassembled cmd is not a write")
+	}
+	svc.enqueueWrites(c)
+	return nil
+}
+
+func (svc *UdpSvc) enqueueWrites(c *cmd.Cmd) {
+	if c.Msg != nil {
+		svc.write <- c.Msg
+	}
+	for _, msg := range c.Msgs {
+		svc.write <- msg
+	}
+}
+
 func (svc *UdpSvc) theThing() {
 	for {
 		select {
 		case msg := <-svc.write:
 			svc.handleWrite(msg)
 		case ping := <-svc.ping:
-			tail, ok := svc.tails[ping]
-			if ok {
-				tail.lastPing = time.Now()
+			// a ping keeps alive all tails of the client
+			for _, tail := range svc.tails {
+				if tail.raddr == ping.raddr {
+					tail.lastPing = time.Now()
+				}
+			}
+			// a ping of a tail unknown, as kicked, asks it to resume
+			if _, ok := svc.tails[ping]; ping.id != 0 && !ok {
+				svc.reply(KickMsg, ping.raddr, ping.id)
 			}
-		case newTail := <-svc.newTail:
-			svc.tails[newTail.raddr.String()] = newTail
+		case t := <-svc.newTail:
+			key := requestKey{t.raddr, t.id}
+			if old, ok := svc.tails[key]; ok {
+				close(old.stop) // resumed
+			}
+			svc.tails[key] = t
+			svc.metrics.tails.Store(int64(len(svc.tails)))
+			svc.startTail(t)
+		case sub := <-svc.subscribe:
+			svc.subscriptions[sub] = struct{}{}
+			svc.metrics.subscriptions.Store(int64(len(svc.subscriptions)))
+		case sub := <-svc.unsubscribe:
+			if _, ok := svc.subscriptions[sub]; ok {
+				delete(svc.subscriptions, sub)
+				close(sub.c)
+			}
+			svc.metrics.subscriptions.Store(int64(len(svc.subscriptions)))
 		case <-time.After(PingPeriod):
-			for _, tail := range svc.tails {
+			for key, tail := range svc.tails {
 				threshold := time.Now().Add(-(PingPeriod * PingLossTolerance))
 				if tail.lastPing.Before(threshold) {
-					delete(svc.tails, tail.raddr.String())
+					delete(svc.tails, key)
 					fmt.Printf("kicked %s\n", tail.raddr.String())
-					svc.reply("kick", tail.raddr)
+					tail.kicked = true
+					close(tail.stop) // the sender replies, as it stops
 				}
 			}
+			svc.metrics.tails.Store(int64(len(svc.tails)))
 		}
 	}
 }
 
-func (svc *UdpSvc) handleWrite(msg *cmd.Msg) error {
-	segments := strings.Split(msg.Key, "/")
+// Write queues msgs to be written to the store and sent to tails, as
+// a WRITE received over udp would be. If the key of any msg is invalid,
+// none are queued.
+func (svc *UdpSvc) Write(msgs ...*cmd.Msg) error {
+	for i, msg := range msgs {
+		_, err := storeKey(msg.GetKey())
+		if err != nil {
+			return fmt.Errorf("msg %d: %w", i, err)
+		}
+	}
+	for _, msg := range msgs {
+		svc.write <- msg
+	}
+	return nil
+}
+
+// storeKey returns the key of the ring that msgs of key are written to
+func storeKey(key string) (string, error) {
+	segments := strings.Split(key, "/")
 	if len(segments) < 3 {
-		return fmt.Errorf("invalid key %q: too few segments", msg.Key)
+		return "", fmt.Errorf("invalid key %q: too few segments", key)
+	}
+	// IMPORTANT:
+	// This is currently how msg keys are mapped to the rings
+	return fmt.Sprintf("/%s/%s", segments[1], segments[2]), nil
+}
+
+func (svc *UdpSvc) handleWrite(msg *cmd.Msg) error {
+	ringKey, err := storeKey(msg.Key)
+	if err != nil {
+		return err
 	}
 	msgBytes, err := proto.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("err marshaling proto msg: %w", err)
 	}
-	// IMPORTANT:
-	// This is currently how msg keys are mapped to the rings
-	storeKey := fmt.Sprintf("/%s/%s", segments[1], segments[2])
-	svc.logStore.Write(storeKey, msgBytes)
-	for raddr, tail := range svc.tails {
-		if !shouldSendToTail(tail, msg) {
+	// a tail taken live is queued every msg written after its heads
+	svc.liveMu.Lock()
+	defer svc.liveMu.Unlock()
+	// the time received, rather than msg.T, as the store
+	// searches record times, so they must be in order
+	err = svc.logStore.Write(ringKey, time.Now(), msgBytes)
+	if err != nil {
+		return fmt.Errorf("err writing to store: %w", err)
+	}
+	// only theThing writes, so the head is just after msg
+	ring, next := svc.logStore.Head(ringKey)
+	entry := &store.Entry{Ring: ring, Seq: next - 1, Data: msgBytes, Msg: msg}
+	svc.publish(msg)
+	for _, tail := range svc.tails {
+		if !tail.live || !shouldSendToTail(tail, msg) {
 			continue
 		}
-		_, err := svc.conn.WriteToUDPAddrPort(msgBytes, tail.raddr)
-		if err != nil {
-			return fmt.Errorf("err writing to %s: %v", raddr, err)
+		if !tail.enqueue(entry) {
+			svc.metrics.tailDrops.Add(1)
 		}
 	}
 	return nil
 }
 
 func shouldSendToTail(t *tail, msg *cmd.Msg) bool {
-	if t.queryParams != nil {
-		keyPrefix := t.queryParams.GetKeyPrefix()
-		if keyPrefix != "" && !strings.HasPrefix(msg.GetKey(), keyPrefix) {
-			return false
-		}
-		qLvl := t.queryParams.GetLvl()
-		if qLvl != cmd.Lvl_LVL_UNKNOWN && qLvl > msg.GetLvl() {
-			return false
+	return tailMatches(t.filter, msg)
+}
+
+func tailMatches(f *filter, msg *cmd.Msg) bool {
+	keyPrefix := f.q.GetKeyPrefix()
+	if keyPrefix != "" && !strings.HasPrefix(msg.GetKey(), keyPrefix) {
+		return false
+	}
+	qLvl := f.q.GetLvl()
+	if qLvl != cmd.Lvl_LVL_UNKNOWN && qLvl > msg.GetLvl() {
+		return false
+	}
+	return txtMatches(msg, f) && attrsMatch(msg, f.q)
+}
+
+func (svc *UdpSvc) reply(txt string, raddr netip.AddrPort, id uint64) {
+	svc.replyMsg(&cmd.Msg{Key: ReplyKey, Txt: txt}, raddr, id)
+}
+
+// replyErr ends the request of id with err, as a reply that the
+// client can tell from results, or as a msg if it has no id
+func (svc *UdpSvc) replyErr(reqErr error, raddr netip.AddrPort, id uint64) {
+	if id == 0 {
+		svc.reply(reqErr.Error(), raddr, id)
+		return
+	}
+	fmt.Printf("err reply to %s: %v\n", raddr, reqErr)
+	raw, err := proto.Marshal(&cmd.Reply{Id: id, Err: reqErr.Error()})
+	if err == nil {
+		var packed []byte
+		packed, err = pkg.Pack(cmd.Compression_NONE, raw)
+		if err == nil {
+			err = svc.writeTo(packed, raddr)
 		}
 	}
-	return true
+	if err != nil {
+		fmt.Printf("err replying to %s: %v\n", raddr, err)
+	}
 }
 
-func (svc *UdpSvc) reply(txt string, raddr netip.AddrPort) {
-	fmt.Printf("reply to %s: %q\n", raddr, txt)
-	payload, err := proto.Marshal(&cmd.Msg{
-		Key: ReplyKey,
-		Txt: txt,
-	})
+// replyMsg sends msg, as a reply carrying the id if set
+func (svc *UdpSvc) replyMsg(msg *cmd.Msg, raddr netip.AddrPort, id uint64) {
+	fmt.Printf("reply to %s: %q\n", raddr, msg.Txt)
+	if id != 0 {
+		err := svc.sendReply(cmd.Compression_NONE, id, []*cmd.Msg{msg}, raddr)
+		if err != nil {
+			fmt.Printf("err replying to %s: %v\n", raddr, err)
+		}
+		return
+	}
+	payload, err := proto.Marshal(msg)
 	if err != nil {
 		fmt.Printf("err marshaling proto msg: %v\n", err)
 		return
 	}
-	_, err = svc.conn.WriteToUDPAddrPort(payload, raddr)
+	err = svc.writeTo(payload, raddr)
 	if err != nil {
 		fmt.Printf("err replying to %s: %v\n", raddr, err)
 		return
 	}
 }
 
-func (svc *UdpSvc) handleQuery(command *cmd.Cmd, raddr netip.AddrPort) {
-	query := command.GetQueryParams()
-	keyPrefix := query.GetKeyPrefix()
-	offset := query.GetOffset()
+// Query reads the msgs matching the query from the store,
+// up to the query hard limit
+func (svc *UdpSvc) Query(query *cmd.QueryParams) (*store.Result, error) {
+	f, err := newFilter(query)
+	if err != nil {
+		return nil, err
+	}
 	limit := query.GetLimit()
 	if limit == 0 || limit > svc.queryHardLimit {
 		limit = svc.queryHardLimit
 	}
-	for log := range svc.logStore.Read(keyPrefix, offset, limit) {
-		msg := &cmd.Msg{}
-		err := proto.Unmarshal(log, msg)
+	var cursor store.Cursor
+	if query.GetCursor() != nil {
+		cursor, err = store.ParseCursor(query.GetCursor())
 		if err != nil {
-			fmt.Println("query unmarshal protobuf err:", err)
-			return
+			return nil, err
+		}
+	}
+	return svc.logStore.Read(&store.Query{
+		KeyPrefix:   query.GetKeyPrefix(),
+		Offset:      query.GetOffset(),
+		Limit:       limit,
+		Cursor:      cursor,
+		OldestFirst: query.GetOrder() == cmd.Order_OLDEST_FIRST,
+		Txt:         query.GetTxt(),
+		Match: func(msg *cmd.Msg) bool {
+			return msgMatchesQuery(msg, f)
+		},
+	}), nil
+}
+
+func (svc *UdpSvc) handleQuery(command *cmd.Cmd, raddr netip.AddrPort) {
+	start := time.Now()
+	query := command.GetQueryParams()
+	res, err := svc.Query(query)
+	if query.GetWindow() > 0 {
+		// an error ends the transfer, so it is sent until acknowledged
+		key := requestKey{raddr, command.Id}
+		t := svc.newTransfer(query, command.Id, res, err, raddr)
+		svc.transfers.Store(key, t)
+		err = t.run()
+		svc.transfers.CompareAndDelete(key, t)
+		svc.ObserveQuery("udp", time.Since(start))
+		if err != nil {
+			fmt.Printf("err sending query replies to %s: %v\n", raddr, err)
+		}
+		return
+	}
+	if err != nil {
+		svc.replyErr(err, raddr, command.Id)
+		return
+	}
+	defer res.Drain() // if sending stopped early
+	if c := query.GetCompression(); c != cmd.Compression_NONE || command.Id != 0 {
+		svc.sendQueryReplies(c, command.Id, res, raddr)
+	} else {
+		for entry := range res.Entries {
+			// possibly wait here a few microseconds
+			// before sending to prevent packet loss
+			err := svc.writeTo(withSeq(entry.Data, entry.Seq), raddr)
+			if err != nil {
+				fmt.Println("err writing to conn:", err)
+				return
+			}
 		}
-		if !msgMatchesQuery(msg, query) {
+	}
+	svc.ObserveQuery("udp", time.Since(start))
+	time.Sleep(15 * time.Millisecond) // ensure +END arrives last
+	svc.replyMsg(&cmd.Msg{
+		Key:    ReplyKey,
+		Txt:    EndMsg,
+		Cursor: res.Cursor().Bytes(),
+	}, raddr, command.Id)
+}
+
+// sendQueryReplies sends the results in batches, compressed if c is set
+func (svc *UdpSvc) sendQueryReplies(c cmd.Compression, id uint64, res *store.Result, raddr netip.AddrPort) {
+	limit := svc.packetBufferSize
+	if c != cmd.Compression_NONE {
+		limit *= maxReplyRatio
+	}
+	batch := make([]*cmd.Msg, 0)
+	size := 0
+	for entry := range res.Entries {
+		entry.Msg.Seq = entry.Seq
+		batch = append(batch, entry.Msg)
+		size += proto.Size(entry.Msg)
+		if size < limit {
 			continue
 		}
-		// possibly wait here a few microseconds
-		// before sending to prevent packet loss
-		_, err = svc.conn.WriteToUDPAddrPort(log, raddr)
+		err := svc.sendReply(c, id, batch, raddr)
 		if err != nil {
-			fmt.Println("err writing to conn:", err)
+			fmt.Println("err sending reply:", err)
 			return
 		}
+		batch, size = batch[:0], 0
 	}
-	time.Sleep(15 * time.Millisecond) // ensure +END arrives last
-	svc.reply(EndMsg, raddr)
+	if len(batch) == 0 {
+		return
+	}
+	err := svc.sendReply(c, id, batch, raddr)
+	if err != nil {
+		fmt.Println("err sending reply:", err)
+	}
+}
+
+// withSeq appends the seq field to a marshaled msg.
+// When unmarshaling, the field is merged into the msg.
+func withSeq(data []byte, seq uint64) []byte {
+	out := make([]byte, len(data), len(data)+protowire.SizeTag(seqFieldNum)+protowire.SizeVarint(seq))
+	copy(out, data)
+	out = protowire.AppendTag(out, seqFieldNum, protowire.VarintType)
+	return protowire.AppendVarint(out, seq)
 }
 
-func msgMatchesQuery(msg *cmd.Msg, query *cmd.QueryParams) bool {
+func msgMatchesQuery(msg *cmd.Msg, f *filter) bool {
+	query := f.q
 	keyPrefix := query.GetKeyPrefix()
 	if keyPrefix != "" && !strings.HasPrefix(msg.GetKey(), keyPrefix) {
 		return false
@@ -292,7 +559,7 @@ func msgMatchesQuery(msg *cmd.Msg, query *cmd.QueryParams) bool {
 	if tEnd != nil && msgT.After(*tEnd) {
 		return false
 	}
-	return true
+	return txtMatches(msg, f) && attrsMatch(msg, query)
 }
 
 func tStart(q *cmd.QueryParams) *time.Time {
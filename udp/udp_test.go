@@ -0,0 +1,41 @@
+package udp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intob/logd/cmd"
+	"github.com/intob/logd/store"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestWriteQueuesAllOrNone(t *testing.T) {
+	svc := &UdpSvc{write: make(chan *cmd.Msg, 3)}
+	err := svc.Write(&cmd.Msg{Key: "/test/app"}, &cmd.Msg{Key: "bad"})
+	require.ErrorContains(t, err, "msg 1")
+	require.Len(t, svc.write, 0)
+	require.NoError(t, svc.Write(&cmd.Msg{Key: "/test/app"}, &cmd.Msg{Key: "/test/app/x"}))
+	require.Len(t, svc.write, 2)
+}
+
+func TestWriteStoresTimeReceived(t *testing.T) {
+	s, err := store.NewStore(&store.Cfg{FallbackSize: 100, DataDir: t.TempDir()})
+	require.NoError(t, err)
+	defer s.Close()
+	svc := &UdpSvc{logStore: s, tails: make(map[requestKey]*tail), metrics: newMetrics()}
+	start := time.Now()
+	// a client with a bad clock
+	require.NoError(t, svc.handleWrite(&cmd.Msg{Key: "/test/app", T: timestamppb.New(time.Unix(0, 0))}))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, svc.handleWrite(&cmd.Msg{Key: "/test/app", T: timestamppb.Now()}))
+	}
+	w := s.Windows()["_fallback"]
+	require.False(t, w.Oldest.Before(start))
+	res := s.Read(&store.Query{Limit: 100, OldestFirst: true, Since: start})
+	n := 0
+	for range res.Entries {
+		n++
+	}
+	require.Equal(t, 11, n)
+}
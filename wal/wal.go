@@ -0,0 +1,245 @@
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	SyncAlways   = "always"   // fsync after every write
+	SyncInterval = "interval" // fsync every SyncEvery
+	SyncNever    = "never"    // leave it to the os
+	// record header: crc32 of payload, payload length
+	headerLen = 4 + 4
+	// payload header: key length, record number, unix nano time
+	payloadHeaderLen = 2 + 8 + 8
+)
+
+// Wal is a write-ahead log of store writes.
+// It is truncated once the writes are safely on disk elsewhere.
+type Wal struct {
+	mu        sync.Mutex
+	file      *os.File
+	size      int64
+	sync      string
+	dirty     bool
+	quit      chan struct{}
+	closeOnce sync.Once
+}
+
+type Cfg struct {
+	Sync      string        `yaml:"sync"`       // always, interval or never
+	SyncEvery time.Duration `yaml:"sync_every"` // for interval policy
+}
+
+// Record is one logged write
+type Record struct {
+	Key  string
+	N    uint64 // record number in the ring's log
+	T    time.Time
+	Data []byte
+}
+
+// Open opens or creates the wal at fname.
+// Call Replay before writing to recover the records left in it.
+func Open(fname string, cfg *Cfg) (*Wal, error) {
+	switch cfg.Sync {
+	case SyncAlways, SyncInterval, SyncNever:
+	default:
+		return nil, fmt.Errorf("invalid sync policy %q", cfg.Sync)
+	}
+	if cfg.Sync == SyncInterval && cfg.SyncEvery <= 0 {
+		return nil, errors.New("sync_every must be positive")
+	}
+	file, err := os.OpenFile(fname, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("err opening file: %w", err)
+	}
+	w := &Wal{
+		file: file,
+		sync: cfg.Sync,
+		quit: make(chan struct{}),
+	}
+	if cfg.Sync == SyncInterval {
+		go w.syncEvery(cfg.SyncEvery)
+	}
+	return w, nil
+}
+
+func (w *Wal) syncEvery(period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.quit:
+			return
+		case <-ticker.C:
+			err := w.Sync()
+			if err != nil {
+				fmt.Println("err syncing wal:", err)
+			}
+		}
+	}
+}
+
+// Replay calls fn for each intact record, in order of writing.
+// The log is truncated after the last intact record,
+// so that a torn write at the tail is removed.
+func (w *Wal) Replay(fn func(r *Record) error) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	info, err := w.file.Stat()
+	if err != nil {
+		return fmt.Errorf("err getting file info: %w", err)
+	}
+	rd := bufio.NewReader(w.file)
+	var good int64
+	for {
+		payload, err := readRecord(rd, info.Size()-good)
+		if err != nil {
+			if err != io.EOF {
+				fmt.Printf("wal: truncating torn tail at %d: %v\n", good, err)
+			}
+			break
+		}
+		r, err := decodeRecord(payload)
+		if err != nil {
+			fmt.Printf("wal: truncating bad record at %d: %v\n", good, err)
+			break
+		}
+		err = fn(r)
+		if err != nil {
+			return err
+		}
+		good += int64(headerLen + len(payload))
+	}
+	err = w.file.Truncate(good)
+	if err != nil {
+		return fmt.Errorf("err truncating: %w", err)
+	}
+	w.size = good
+	return w.file.Sync()
+}
+
+// readRecord reads the next record, of no more than left bytes,
+// so that the length of a torn record can't exhaust memory
+func readRecord(rd io.Reader, left int64) ([]byte, error) {
+	header := make([]byte, headerLen)
+	_, err := io.ReadFull(rd, header)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	payloadLen := int64(binary.BigEndian.Uint32(header[4:]))
+	if payloadLen > left-headerLen {
+		return nil, fmt.Errorf("length %d exceeds the %d bytes left", payloadLen, left-headerLen)
+	}
+	payload := make([]byte, payloadLen)
+	_, err = io.ReadFull(rd, payload)
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header) {
+		return nil, errors.New("checksum mismatch")
+	}
+	return payload, nil
+}
+
+func decodeRecord(payload []byte) (*Record, error) {
+	if len(payload) < payloadHeaderLen {
+		return nil, errors.New("payload too short")
+	}
+	keyLen := int(binary.BigEndian.Uint16(payload))
+	if len(payload) < payloadHeaderLen+keyLen {
+		return nil, errors.New("key too long")
+	}
+	return &Record{
+		Key:  string(payload[2 : 2+keyLen]),
+		N:    binary.BigEndian.Uint64(payload[2+keyLen:]),
+		T:    time.Unix(0, int64(binary.BigEndian.Uint64(payload[10+keyLen:]))),
+		Data: payload[payloadHeaderLen+keyLen:],
+	}, nil
+}
+
+// Append writes the record, and syncs if the policy is always
+func (w *Wal) Append(r *Record) error {
+	if len(r.Key) > 1<<16-1 {
+		return errors.New("key too long")
+	}
+	payloadLen := payloadHeaderLen + len(r.Key) + len(r.Data)
+	buf := make([]byte, headerLen+payloadLen)
+	binary.BigEndian.PutUint32(buf[4:], uint32(payloadLen))
+	payload := buf[headerLen:]
+	binary.BigEndian.PutUint16(payload, uint16(len(r.Key)))
+	copy(payload[2:], r.Key)
+	binary.BigEndian.PutUint64(payload[2+len(r.Key):], r.N)
+	binary.BigEndian.PutUint64(payload[10+len(r.Key):], uint64(r.T.UnixNano()))
+	copy(payload[payloadHeaderLen+len(r.Key):], r.Data)
+	binary.BigEndian.PutUint32(buf, crc32.ChecksumIEEE(payload))
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.file.WriteAt(buf, w.size)
+	if err != nil {
+		return fmt.Errorf("err writing record: %w", err)
+	}
+	w.size += int64(len(buf))
+	if w.sync == SyncAlways {
+		return w.file.Sync()
+	}
+	w.dirty = true
+	return nil
+}
+
+// Size returns the size of the log in bytes
+func (w *Wal) Size() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.size
+}
+
+// Sync flushes the log to disk, if written since last sync
+func (w *Wal) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.dirty {
+		return nil
+	}
+	w.dirty = false
+	return w.file.Sync()
+}
+
+// Reset empties the log. The caller must ensure that all
+// records are safely on disk elsewhere.
+func (w *Wal) Reset() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.file.Truncate(0)
+	if err != nil {
+		return err
+	}
+	w.size = 0
+	w.dirty = false
+	return w.file.Sync()
+}
+
+func (w *Wal) Close() error {
+	w.closeOnce.Do(func() { close(w.quit) })
+	err := w.Sync()
+	if err != nil {
+		return err
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,106 @@
+package wal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var cfg = &Cfg{Sync: SyncAlways}
+
+func TestReplay(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "wal")
+	w, err := Open(fname, cfg)
+	require.NoError(t, err)
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, w.Append(&Record{
+			Key:  "/test/app",
+			N:    uint64(i),
+			T:    now,
+			Data: []byte(fmt.Sprintf("record %d", i)),
+		}))
+	}
+	require.NoError(t, w.Close())
+	w, err = Open(fname, cfg)
+	require.NoError(t, err)
+	defer w.Close()
+	var replayed []*Record
+	require.NoError(t, w.Replay(func(r *Record) error {
+		replayed = append(replayed, r)
+		return nil
+	}))
+	require.Len(t, replayed, 10)
+	for i, r := range replayed {
+		require.Equal(t, "/test/app", r.Key)
+		require.Equal(t, uint64(i), r.N)
+		require.Equal(t, now.UnixNano(), r.T.UnixNano())
+		require.Equal(t, fmt.Sprintf("record %d", i), string(r.Data))
+	}
+}
+
+func TestReplayTruncatesTornTail(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "wal")
+	w, err := Open(fname, cfg)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, w.Append(&Record{Key: "/test/app", N: uint64(i), T: time.Now(), Data: []byte("test")}))
+	}
+	good := w.Size()
+	require.NoError(t, w.Close())
+	// a torn record, followed by garbage
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{1, 2, 3, 4, 0, 0, 0, 40, 5, 6})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	w, err = Open(fname, cfg)
+	require.NoError(t, err)
+	defer w.Close()
+	var n int
+	require.NoError(t, w.Replay(func(r *Record) error {
+		n++
+		return nil
+	}))
+	require.Equal(t, 3, n)
+	require.Equal(t, good, w.Size())
+	info, err := os.Stat(fname)
+	require.NoError(t, err)
+	require.Equal(t, good, info.Size())
+}
+
+func TestInvalidSyncPolicy(t *testing.T) {
+	_, err := Open(filepath.Join(t.TempDir(), "wal"), &Cfg{Sync: "sometimes"})
+	require.Error(t, err)
+}
+
+func TestReplayBoundsRecordLength(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "wal")
+	w, err := Open(fname, cfg)
+	require.NoError(t, err)
+	require.NoError(t, w.Append(&Record{Key: "/test/app", T: time.Now(), Data: []byte("test")}))
+	good := w.Size()
+	require.NoError(t, w.Close())
+	// a garbage header, claiming a record of almost 4GiB
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{1, 2, 3, 4, 0xff, 0xff, 0xff, 0xf0, 5, 6})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	w, err = Open(fname, cfg)
+	require.NoError(t, err)
+	defer w.Close()
+	var n int
+	require.NoError(t, w.Replay(func(r *Record) error {
+		n++
+		return nil
+	}))
+	require.Equal(t, 1, n)
+	require.Equal(t, good, w.Size())
+}